@@ -1,12 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"image"
 	"image/color"
-	"io"
 	"net"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/lucasb-eyer/go-colorful"
@@ -14,8 +15,11 @@ import (
 	"github.com/ninjasphere/go-ninja/config"
 	"github.com/ninjasphere/go-ninja/logger"
 	"github.com/ninjasphere/go-ninja/model"
+	"github.com/ninjasphere/sphere-go-led-controller/gestures"
 	ledmodel "github.com/ninjasphere/sphere-go-led-controller/model"
+	"github.com/ninjasphere/sphere-go-led-controller/preview"
 	"github.com/ninjasphere/sphere-go-led-controller/remote"
+	"github.com/ninjasphere/sphere-go-led-controller/stream"
 	"github.com/ninjasphere/sphere-go-led-controller/ui"
 	"github.com/ninjasphere/sphere-go-led-controller/util"
 )
@@ -25,9 +29,14 @@ var log = logger.GetLogger("sphere-go-led-controller")
 var enableRemotePanes = config.Bool(false, "led.remote.enabled")
 var remotePort = config.Int(3115, "led.remote.port")
 
-var fps Tick = Tick{
-	name: "Pane FPS",
-}
+var matrixDriver = config.String("serial", "led.driver")
+
+var gestureSource = config.String("gestic", "led.gestures.source")
+
+var layoutFile = config.String("layout.json", "led.layout.file")
+
+// The number of frames in a row that can fail to be written before we give up
+const maxWriteFailures = 10
 
 type LedController struct {
 	controlEnabled   bool
@@ -36,37 +45,78 @@ type LedController struct {
 	commandReceived  bool
 
 	controlLayout *ui.PaneLayout
+	controlLock   sync.Mutex // held while controlLayout or controlEnabled is set, see control()
 	pairingLayout *ui.PairingLayout
 	conn          *ninja.Connection
-	serial        io.ReadWriteCloser
-	waiting       chan bool
+	matrix        util.Matrix
+	services      []*ninja.ExportedService
+	governor      *thermalGovernor
+	brightness    *brightnessControl
+
+	remotePanes []ui.Pane
+	remoteLock  sync.Mutex
+	waiting     chan bool
+
+	writeFailures int
+
+	scheduler         *frameScheduler
+	stats             *renderStats
+	lastFrame         []byte // the pixels last written to the matrix, or nil if the last write failed
+	lastFrameLock     sync.Mutex
+	shownFrame        []byte  // the pixels last successfully written to the matrix
+	writtenBrightness float64 // the brightness of the last frame written
+
+	preview *preview.Server
+
+	temporaryDisplays []*temporaryDisplay // the displays shown for a while, newest last
+	displayLock       sync.Mutex
+
+	stream *stream.Sink
 }
 
 func NewLedController(conn *ninja.Connection) (*LedController, error) {
 
-	s, err := util.GetLEDConnection()
+	matrix, err := util.NewMatrix(matrixDriver)
 
 	if err != nil {
+		log.Fatalf("Failed to create LED matrix driver: %s", err)
+	}
+
+	if err := matrix.Open(); err != nil {
 		log.Fatalf("Failed to get connection to LED matrix: %s", err)
 	}
 
-	// Send a blank image to the led matrix
-	util.WriteLEDMatrix(image.NewRGBA(image.Rect(0, 0, 16, 16)), s)
+	log.Infof("Connected to LED matrix: %+v", matrix.Info())
 
 	controller := &LedController{
 		conn:          conn,
 		pairingLayout: ui.NewPairingLayout(),
-		serial:        s,
+		matrix:        matrix,
+		governor:      newThermalGovernor(),
+		brightness:    newBrightnessControl(),
 		waiting:       make(chan bool),
+		stats:         newRenderStats(),
+	}
+	controller.scheduler = &frameScheduler{stats: controller.stats}
+
+	// Send a blank image to the led matrix
+	controller.write(image.NewRGBA(image.Rect(0, 0, 16, 16)))
+
+	if conn == nil {
+		// Running offline, so there's nothing to export to or check for homecloud on
+		controller.startSamplingTemperature()
+		return controller, nil
 	}
 
-	conn.MustExportService(controller, "$node/"+config.Serial()+"/led-controller", &model.ServiceAnnouncement{
+	controller.services = append(controller.services, conn.MustExportService(controller, "$node/"+config.Serial()+"/led-controller", &model.ServiceAnnouncement{
 		Schema: "/service/led-controller",
-	})
+	}))
 
-	conn.MustExportService(controller, "$home/led-controller", &model.ServiceAnnouncement{
+	controller.services = append(controller.services, conn.MustExportService(controller, "$home/led-controller", &model.ServiceAnnouncement{
 		Schema: "/service/led-controller",
-	})
+	}))
+
+	controller.startSamplingTemperature()
 
 	if config.HasString("siteId") {
 		log.Infof("Have a siteId, checking if homecloud is running")
@@ -102,21 +152,27 @@ func (c *LedController) start(enableControl bool) {
 	frameWritten := make(chan bool)
 
 	go func() {
-		fps.start()
-
 		for {
-			fps.tick()
-
 			if c.controlEnabled {
 				// Good to go
 
+				c.scheduler.wait(c.controlLayout.FrameRate)
+
+				if !c.controlLayout.IsDirty() && !c.brightnessChanged() {
+					c.stats.clean()
+					continue
+				}
+
+				start := time.Now()
 				image, wake, err := c.controlLayout.Render()
 				if err != nil {
 					log.Fatalf("Unable to render()", err)
 				}
+				c.stats.rendered(time.Since(start))
+				c.publish(image)
 
 				go func() {
-					util.WriteLEDMatrix(image, c.serial)
+					c.write(image)
 					frameWritten <- true
 				}()
 
@@ -146,9 +202,14 @@ func (c *LedController) start(enableControl bool) {
 				go func() {
 
 					log.Infof("Starting control layout")
-					c.controlLayout = getPaneLayout(c.conn)
-					c.controlRendering = false
+					layout := c.getPaneLayout()
+
+					c.controlLock.Lock()
+					c.controlLayout = layout
 					c.controlEnabled = true
+					c.controlLock.Unlock()
+
+					c.controlRendering = false
 					log.Infof("Finished control layout")
 
 				}()
@@ -157,11 +218,21 @@ func (c *LedController) start(enableControl bool) {
 			if c.controlRendering || !c.controlEnabled {
 				// We're either already controlling, or waiting for the pane to render
 
+				c.scheduler.wait(c.pairingLayout.FrameRate)
+
+				if !c.pairingLayout.IsDirty() && !c.brightnessChanged() {
+					c.stats.clean()
+					continue
+				}
+
+				start := time.Now()
 				image, err := c.pairingLayout.Render()
 				if err != nil {
 					log.Fatalf("Unable to render()", err)
 				}
-				util.WriteLEDMatrix(image, c.serial)
+				c.stats.rendered(time.Since(start))
+				c.publish(image)
+				c.write(image)
 
 			}
 		}
@@ -169,18 +240,79 @@ func (c *LedController) start(enableControl bool) {
 	}()
 }
 
+// currentBrightness answers the brightness frames are shown at.
+func (c *LedController) currentBrightness() float64 {
+	return c.brightness.brightness() * c.governor.brightness()
+}
+
+// brightnessChanged answers whether the display needs to be redrawn at a new brightness.
+func (c *LedController) brightnessChanged() bool {
+	return c.currentBrightness() != c.writtenBrightness
+}
+
+// write sends a frame to the led matrix, at the global brightness. A few failed frames
+// are tolerated, but if the matrix has really gone away we quit and let upstart restart us.
+func (c *LedController) write(frame *image.RGBA) {
+	c.writtenBrightness = c.currentBrightness()
+	if c.writtenBrightness < 1 {
+		frame = util.ScaleBrightness(frame, c.writtenBrightness)
+	}
+
+	if c.lastFrame != nil && bytes.Equal(c.lastFrame, frame.Pix) {
+		c.stats.unchanged()
+		return
+	}
+
+	start := time.Now()
+	if err := c.matrix.WriteFrame(frame); err != nil {
+		c.lastFrame = nil
+		c.stats.failed()
+		c.writeFailures++
+		log.Errorf("Failed writing frame to LED matrix (%d in a row): %s", c.writeFailures, err)
+
+		if c.writeFailures >= maxWriteFailures {
+			log.Fatalf("Too many failures writing to LED matrix. Quitting.")
+		}
+		return
+	}
+	c.writeFailures = 0
+	c.stats.written(time.Since(start))
+
+	// Copied, as panes can draw into the frames they answer
+	c.lastFrameLock.Lock()
+	c.lastFrame = append(c.lastFrame[:0], frame.Pix...)
+	c.shownFrame = c.lastFrame
+	c.lastFrameLock.Unlock()
+}
+
+// currentFrame answers a copy of the frame on the matrix.
+func (c *LedController) currentFrame() *image.RGBA {
+	frame := image.NewRGBA(image.Rect(0, 0, 16, 16))
+
+	c.lastFrameLock.Lock()
+	copy(frame.Pix, c.shownFrame)
+	c.lastFrameLock.Unlock()
+
+	return frame
+}
+
 func (c *LedController) EnableControl() error {
+	c.clearTemporaryDisplays()
+	c.enableControl()
+	return nil
+}
+
+func (c *LedController) enableControl() {
 	log.Infof("Enabling control. Already enabled? %t", c.controlEnabled)
 	if !c.controlEnabled {
 		if c.controlLayout != nil {
 			// Pane layout has already been rendered. Just re-enable control.
-			c.controlEnabled = true
+			c.setControlEnabled(true)
 		} else {
 			c.controlRequested = true
 		}
 		c.gotCommand()
 	}
-	return nil
 }
 
 func (c *LedController) disableControl() error {
@@ -190,60 +322,94 @@ func (c *LedController) disableControl() error {
 		Icon: "spinner-red.gif",
 	})
 
-	c.controlEnabled = false
+	c.setControlEnabled(false)
 	c.controlRequested = false
 	return nil
 }
 
-func (c *LedController) DisableControl() error {
-	c.disableControl()
-	c.gotCommand()
-	return nil
+// setControlEnabled shows or hides the control layout.
+func (c *LedController) setControlEnabled(enabled bool) {
+	c.controlLock.Lock()
+	defer c.controlLock.Unlock()
+	c.controlEnabled = enabled
 }
 
-type PairingCodeRequest struct {
-	Code        string `json:"code"`
-	DisplayTime int    `json:"displayTime"`
+// control answers the control layout (or nil, if it hasn't been started yet) and
+// whether it's showing. It's for goroutines other than the render loop, e.g. the rpc
+// and http handlers.
+func (c *LedController) control() (*ui.PaneLayout, bool) {
+	c.controlLock.Lock()
+	defer c.controlLock.Unlock()
+	return c.controlLayout, c.controlEnabled
 }
 
-func (c *LedController) DisplayPairingCode(req *PairingCodeRequest) error {
-	c.pairingLayout.ShowCode(req.Code)
+func (c *LedController) DisableControl() error {
+	c.disableControl()
 	c.gotCommand()
 	return nil
 }
 
-type ColorRequest struct {
-	Color       string `json:"color"`
-	DisplayTime int    `json:"displayTime"`
+func (c *LedController) DisplayPairingCode(req *ledmodel.PairingCodeRequest) error {
+	return c.display(req.DisplayTime, func() error {
+		c.pairingLayout.ShowCode(req.Code)
+		return nil
+	})
 }
 
-func (c *LedController) DisplayColor(req *ColorRequest) error {
+func (c *LedController) DisplayColor(req *ledmodel.ColorRequest) error {
 	col, err := colorful.Hex(req.Color)
 
 	if err != nil {
 		return err
 	}
 
-	c.pairingLayout.ShowColor(col)
-	c.gotCommand()
-	return nil
+	return c.display(req.DisplayTime, func() error {
+		c.pairingLayout.ShowColor(col)
+		return nil
+	})
 }
 
 func (c *LedController) DisplayIcon(req *ledmodel.IconRequest) error {
 	log.Infof("Displaying icon: %v", req)
-	c.pairingLayout.ShowIcon(req.Icon)
-	c.gotCommand()
-	return nil
+	return c.display(req.DisplayTime, func() error {
+		if err := c.pairingLayout.ShowIcon(req.Icon); err != nil {
+			return fmt.Errorf("Failed to display icon %s: %s", req.Icon, err)
+		}
+		return nil
+	})
 }
 
-func (c *LedController) DisplayDrawing() error {
-	c.pairingLayout.ShowDrawing()
-	return nil
-}
+// DisplayText shows a line or two of text, scrolling any that is too wide. If it only
+// scrolls a few times, it's shown until it has finished unless it has a display time.
+func (c *LedController) DisplayText(req *ledmodel.TextRequest) error {
+	var col color.Color = color.White
+	if req.Color != "" {
+		var err error
+		if col, err = colorful.Hex(req.Color); err != nil {
+			return fmt.Errorf("Bad text color %s: %s", req.Color, err)
+		}
+	}
 
-func (c *LedController) Draw(updates *[][]uint8) error {
-	c.pairingLayout.Draw(updates)
-	return nil
+	marquee, err := ui.NewMarqueePane(req.Text, ui.MarqueeOptions{
+		Color:  col,
+		Font:   req.Font,
+		Speed:  req.Speed,
+		Repeat: req.Repeat,
+		Align:  req.Align,
+	})
+	if err != nil {
+		return err
+	}
+
+	displayTime := req.DisplayTime
+	if displayTime == 0 {
+		displayTime = int(marquee.Duration() / time.Millisecond)
+	}
+
+	return c.display(displayTime, func() error {
+		c.pairingLayout.ShowMarquee(marquee)
+		return nil
+	})
 }
 
 func (c *LedController) DisplayResetMode(m *ledmodel.ResetMode) error {
@@ -291,6 +457,15 @@ func (c *LedController) DisplayUpdateProgress(p *ledmodel.DisplayUpdateProgress)
 	return nil
 }
 
+// sendEvent publishes an event on each of our exported services.
+func (c *LedController) sendEvent(event string, payload interface{}) {
+	for _, service := range c.services {
+		if err := service.SendEvent(event, payload); err != nil {
+			log.Warningf("Failed to send %s event: %s", event, err)
+		}
+	}
+}
+
 func (c *LedController) gotCommand() {
 	select {
 	case c.waiting <- true:
@@ -299,49 +474,27 @@ func (c *LedController) gotCommand() {
 	c.commandReceived = true
 }
 
-// Load from a config file instead...
-func getPaneLayout(conn *ninja.Connection) *ui.PaneLayout {
-	layout, wake := ui.NewPaneLayout(false, conn)
-
-	layout.AddPane(ui.NewClockPane())
-	layout.AddPane(ui.NewWeatherPane(conn))
-	layout.AddPane(ui.NewGesturePane())
-	layout.AddPane(ui.NewGameOfLifePane())
-	layout.AddPane(ui.NewMediaPane(conn))
-	layout.AddPane(ui.NewCertPane(conn.GetMqttClient()))
-
-	//layout.AddPane(ui.NewTextScrollPane("Exit Music (For A Film)"))
-	lampPane := ui.NewOnOffPane(util.ResolveImagePath("lamp2-off.gif"), util.ResolveImagePath("lamp2-on.gif"), func(state bool) {
-		log.Debugf("Lamp state: %t", state)
-	}, conn, "lamp")
-	layout.AddPane(lampPane)
-
-	heaterPane := ui.NewOnOffPane(util.ResolveImagePath("heater-off.png"), util.ResolveImagePath("heater-on.gif"), func(state bool) {
-		log.Debugf("Heater state: %t", state)
-	}, conn, "heater")
-	layout.AddPane(heaterPane)
-
-	brightnessPane := ui.NewLightPane(false, util.ResolveImagePath("light-off.png"), util.ResolveImagePath("light-on.png"), conn)
-	layout.AddPane(brightnessPane)
-
-	colorPane := ui.NewLightPane(true, util.ResolveImagePath("light-off.png"), util.ResolveImagePath("light-on.png"), conn)
-	layout.AddPane(colorPane)
-
-	fanPane := ui.NewOnOffPane(util.ResolveImagePath("fan-off.png"), util.ResolveImagePath("fan-on.gif"), func(state bool) {
-		log.Debugf("Fan state: %t", state)
-	}, conn, "fan")
+// getPaneLayout builds the control layout from the layout file.
+func (c *LedController) getPaneLayout() *ui.PaneLayout {
+	source, err := gestures.NewSource(gestureSource)
+	if err != nil {
+		log.Fatalf("Failed to create gesture source: %s", err)
+	}
 
-	layout.AddPane(fanPane)
+	layout, wake := ui.NewPaneLayout(source, c.conn)
 
-	airconPane := ui.NewOnOffPane(util.ResolveImagePath("fan-off.png"), util.ResolveImagePath("fan-on.gif"), func(state bool) {
-		log.Debugf("aircon state: %t", state)
-	}, conn, "aircon")
-	layout.AddPane(airconPane)
+	panes, err := loadPanes(c.conn)
+	if err != nil {
+		log.Fatalf("Failed to load pane layout: %s", err)
+	}
+	layout.SetPanes(panes)
 
-	layout.AddPane(ui.NewSystemPane(conn))
+	if c.stream != nil && streamMode == "pane" {
+		c.addRemotePane(layout, c.stream)
+	}
 
 	if enableRemotePanes {
-		if err := listenForRemotePanes(layout); err != nil {
+		if err := c.listenForRemotePanes(layout); err != nil {
 			log.Fatalf("Failed to start listening for remote panes: %s", err)
 		}
 	}
@@ -355,7 +508,45 @@ func getPaneLayout(conn *ninja.Connection) *ui.PaneLayout {
 	return layout
 }
 
-func listenForRemotePanes(layout *ui.PaneLayout) error {
+func loadPanes(conn *ninja.Connection) ([]ui.Pane, error) {
+	config, err := ui.LoadLayoutConfig(layoutFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return ui.BuildPanes(config, conn)
+}
+
+// ReloadLayout rebuilds the control layout's panes from the layout file. Any
+// connected remote panes are kept.
+func (c *LedController) ReloadLayout() error {
+	layout, _ := c.control()
+	if layout == nil {
+		return fmt.Errorf("The control layout hasn't been started yet")
+	}
+
+	panes, err := loadPanes(c.conn)
+	if err != nil {
+		return err
+	}
+
+	c.remoteLock.Lock()
+	defer c.remoteLock.Unlock()
+
+	panes = append(panes, c.remotePanes...)
+
+	log.Infof("Reloaded pane layout from %s. %d panes.", layoutFile, len(panes))
+
+	layout.SetPanes(panes)
+	return nil
+}
+
+// GetPaneTypes answers the types of pane that can be used in the pane layout.
+func (c *LedController) GetPaneTypes() ([]string, error) {
+	return ui.PaneTypes(), nil
+}
+
+func (c *LedController) listenForRemotePanes(layout *ui.PaneLayout) error {
 
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", remotePort))
 	if err != nil {
@@ -374,10 +565,10 @@ func listenForRemotePanes(layout *ui.PaneLayout) error {
 				log.Infof("Remote pane connected.")
 
 				pane := remote.NewPane(conn)
-				layout.AddPane(pane)
+				c.addRemotePane(layout, pane)
 				<-pane.Disconnected
 				log.Infof("Remote pane disconnected.")
-				layout.RemovePane(pane)
+				c.removeRemotePane(layout, pane)
 			}()
 		}
 	}()
@@ -385,21 +576,22 @@ func listenForRemotePanes(layout *ui.PaneLayout) error {
 	return nil
 }
 
-type Tick struct {
-	count int
-	name  string
-}
-
-func (t *Tick) tick() {
-	t.count++
+// Remote panes are tracked as well as added to the layout, so they survive a reload.
+func (c *LedController) addRemotePane(layout *ui.PaneLayout, pane ui.Pane) {
+	c.remoteLock.Lock()
+	defer c.remoteLock.Unlock()
+	c.remotePanes = append(c.remotePanes, pane)
+	layout.AddPane(pane)
 }
 
-func (t *Tick) start() {
-	go func() {
-		for {
-			time.Sleep(time.Second)
-			//log.Debugf("%s - %d", t.name, t.count)
-			t.count = 0
+func (c *LedController) removeRemotePane(layout *ui.PaneLayout, pane ui.Pane) {
+	c.remoteLock.Lock()
+	defer c.remoteLock.Unlock()
+	layout.RemovePane(pane)
+	for i, p := range c.remotePanes {
+		if p == pane {
+			c.remotePanes = append(c.remotePanes[:i], c.remotePanes[i+1:]...)
+			return
 		}
-	}()
+	}
 }
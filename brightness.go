@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ninjasphere/go-ninja/config"
+	ledmodel "github.com/ninjasphere/sphere-go-led-controller/model"
+	"github.com/ninjasphere/sphere-go-led-controller/ui"
+	"github.com/ninjasphere/sphere-go-led-controller/util"
+)
+
+var defaultBrightness = config.Float(1, "led.brightness")
+
+// Night mode dims the display between sunset and sunrise at the site, or between
+// led.night.start and led.night.end (e.g. "22:00" and "07:00") if they are set.
+var nightEnabled = config.Bool(false, "led.night.enabled")
+var nightBrightness = config.Float(0.2, "led.night.brightness")
+var nightStart = config.String("", "led.night.start")
+var nightEnd = config.String("", "led.night.end")
+
+// How often we work out if it's night time
+const nightCheckInterval = time.Minute
+
+// brightnessControl holds the brightness of the whole display, as set over rpc or
+// by the night schedule.
+type brightnessControl struct {
+	sync.Mutex
+	state     ledmodel.BrightnessState
+	lastCheck time.Time
+}
+
+func newBrightnessControl() *brightnessControl {
+	brightness := clampBrightness(defaultBrightness)
+	return &brightnessControl{
+		state: ledmodel.BrightnessState{
+			Brightness:      brightness,
+			NightEnabled:    nightEnabled,
+			NightBrightness: clampBrightness(nightBrightness),
+			Effective:       brightness,
+		},
+	}
+}
+
+func clampBrightness(b float64) float64 {
+	if b < 0 {
+		return 0
+	}
+	if b > 1 {
+		return 1
+	}
+	return b
+}
+
+// brightness answers the brightness the display should be at right now.
+func (b *brightnessControl) brightness() float64 {
+	b.Lock()
+	defer b.Unlock()
+
+	if time.Since(b.lastCheck) > nightCheckInterval {
+		b.lastCheck = time.Now()
+		b.updateNight(time.Now())
+	}
+
+	return b.state.Effective
+}
+
+func (b *brightnessControl) updateNight(now time.Time) {
+	night := false
+
+	if b.state.NightEnabled {
+		var err error
+		night, err = isNight(now)
+		if err != nil {
+			log.Debugf("Can't tell if it's night yet: %s", err)
+		}
+	}
+
+	if night != b.state.Night {
+		log.Infof("Night mode is now %t", night)
+	}
+	b.state.Night = night
+
+	b.state.Effective = b.state.Brightness
+	if night {
+		b.state.Effective *= b.state.NightBrightness
+	}
+}
+
+func (b *brightnessControl) set(req *ledmodel.BrightnessRequest) error {
+	b.Lock()
+	defer b.Unlock()
+
+	for _, value := range []*float64{req.Brightness, req.NightBrightness} {
+		if value != nil && (*value < 0 || *value > 1) {
+			return fmt.Errorf("Brightness must be between 0 and 1. Got %f", *value)
+		}
+	}
+
+	if req.Brightness != nil {
+		b.state.Brightness = *req.Brightness
+	}
+	if req.NightBrightness != nil {
+		b.state.NightBrightness = *req.NightBrightness
+	}
+	if req.NightEnabled != nil {
+		b.state.NightEnabled = *req.NightEnabled
+	}
+
+	b.lastCheck = time.Now()
+	b.updateNight(b.lastCheck)
+
+	return nil
+}
+
+func (b *brightnessControl) get() ledmodel.BrightnessState {
+	b.Lock()
+	defer b.Unlock()
+	return b.state
+}
+
+// isNight answers whether it's currently night at the site, using the fixed schedule
+// if there is one, otherwise sunset and sunrise.
+func isNight(now time.Time) (bool, error) {
+	site, timezone := ui.GetSite()
+
+	if timezone != nil {
+		now = now.In(timezone)
+	}
+
+	if nightStart != "" && nightEnd != "" {
+		start, err := time.Parse("15:04", nightStart)
+		if err != nil {
+			return false, fmt.Errorf("Bad led.night.start: %s", err)
+		}
+		end, err := time.Parse("15:04", nightEnd)
+		if err != nil {
+			return false, fmt.Errorf("Bad led.night.end: %s", err)
+		}
+
+		minutes := now.Hour()*60 + now.Minute()
+		startMinutes := start.Hour()*60 + start.Minute()
+		endMinutes := end.Hour()*60 + end.Minute()
+
+		if startMinutes > endMinutes {
+			// Wraps around midnight
+			return minutes >= startMinutes || minutes < endMinutes, nil
+		}
+		return minutes >= startMinutes && minutes < endMinutes, nil
+	}
+
+	if site == nil || site.Latitude == nil || site.Longitude == nil {
+		return false, fmt.Errorf("The site's location isn't known")
+	}
+
+	sunrise, sunset, err := util.SunriseSunset(*site.Latitude, *site.Longitude, now)
+	switch err {
+	case nil:
+		return now.Before(sunrise) || now.After(sunset), nil
+	case util.ErrSunAlwaysUp:
+		return false, nil
+	case util.ErrSunAlwaysDown:
+		return true, nil
+	}
+	return false, err
+}
+
+// SetBrightness sets the brightness of the whole display, and the night schedule.
+func (c *LedController) SetBrightness(req *ledmodel.BrightnessRequest) error {
+	if err := c.brightness.set(req); err != nil {
+		return err
+	}
+	state := c.brightness.get()
+	log.Infof("Brightness set to: %+v", state)
+	c.sendEvent("brightness", &state)
+	return nil
+}
+
+// GetBrightness answers the brightness of the display, and whether night mode is active.
+func (c *LedController) GetBrightness() (*ledmodel.BrightnessState, error) {
+	c.brightness.brightness() // make sure night mode is up to date
+	state := c.brightness.get()
+	return &state, nil
+}
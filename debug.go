@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+
+	"github.com/ninjasphere/go-ninja/config"
+	ledmodel "github.com/ninjasphere/sphere-go-led-controller/model"
+	"github.com/ninjasphere/sphere-go-led-controller/util"
+)
+
+var enableDebugServer = config.Bool(false, "led.debug.enabled")
+var debugAddress = config.String("localhost:6060", "led.debug.address")
+
+// The largest scale the live frame can be drawn at
+const maxFrameScale = 64
+
+type debugMetrics struct {
+	Render *ledmodel.RenderStats `json:"render"`
+	Layout *ledmodel.LayoutStats `json:"layout,omitempty"` // missing until the control layout has started
+	Matrix util.MatrixInfo       `json:"matrix"`
+}
+
+// startDebugServer serves pprof, the render metrics and the frame on the matrix over http.
+func (c *LedController) startDebugServer() {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+
+	mux.HandleFunc("/metrics", c.serveMetrics)
+	mux.HandleFunc("/frame.png", c.serveFrame)
+
+	go func() {
+		log.Infof("Starting debug server on %s", debugAddress)
+		if err := http.ListenAndServe(debugAddress, mux); err != nil {
+			log.Errorf("Debug server failed: %s", err)
+		}
+	}()
+}
+
+func (c *LedController) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	metrics := &debugMetrics{
+		Render: c.stats.get(),
+		Matrix: c.matrix.Info(),
+	}
+
+	if layout, _ := c.control(); layout != nil {
+		metrics.Layout = layout.Stats()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(metrics); err != nil {
+		log.Warningf("Failed to write metrics: %s", err)
+	}
+}
+
+// serveFrame answers a png of the last frame written to the matrix, with each led drawn
+// as a square of ?scale= pixels (default 16).
+func (c *LedController) serveFrame(w http.ResponseWriter, r *http.Request) {
+	scale := 16
+	if s := r.URL.Query().Get("scale"); s != "" {
+		var err error
+		if scale, err = strconv.Atoi(s); err != nil || scale < 1 || scale > maxFrameScale {
+			http.Error(w, "scale must be between 1 and 64", http.StatusBadRequest)
+			return
+		}
+	}
+
+	frame := c.currentFrame()
+	out := image.NewRGBA(image.Rect(0, 0, frame.Rect.Dx()*scale, frame.Rect.Dy()*scale))
+
+	for y := 0; y < out.Rect.Dy(); y++ {
+		for x := 0; x < out.Rect.Dx(); x++ {
+			s := frame.PixOffset(x/scale, y/scale)
+			d := out.PixOffset(x, y)
+			copy(out.Pix[d:d+3], frame.Pix[s:s+3])
+			out.Pix[d+3] = 255
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := png.Encode(w, out); err != nil {
+		log.Warningf("Failed to write frame: %s", err)
+	}
+}
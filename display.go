@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ninjasphere/sphere-go-led-controller/ui"
+)
+
+// displayState is what's on the display: the control layout, or whatever the pairing
+// layout is showing.
+type displayState struct {
+	control bool
+	pairing ui.PairingState
+}
+
+// temporaryDisplay is something shown on the pairing layout for a while, after which
+// the display goes back to the previous state.
+type temporaryDisplay struct {
+	previous displayState
+	timer    *time.Timer // or nil, if it's shown until it's ended
+}
+
+func (c *LedController) currentDisplay() displayState {
+	return displayState{
+		control: c.controlEnabled || c.controlRequested,
+		pairing: c.pairingLayout.State(),
+	}
+}
+
+func (c *LedController) restoreDisplay(state displayState) {
+	c.pairingLayout.Restore(state.pairing)
+
+	if state.control {
+		c.enableControl()
+	} else {
+		c.setControlEnabled(false)
+		c.controlRequested = false
+		c.gotCommand()
+	}
+}
+
+// display shows something on the pairing layout. If displayTime (in milliseconds) is 0
+// it's shown until something else is, as before. Otherwise it takes over the display
+// (even from the control layout) until the time is up, then the display goes back to
+// how it was. Temporary displays can be nested, and unwind in order as they finish.
+func (c *LedController) display(displayTime int, show func() error) error {
+	if displayTime < 0 {
+		return fmt.Errorf("Bad display time: %d", displayTime)
+	}
+
+	if displayTime == 0 {
+		c.clearTemporaryDisplays()
+		err := show()
+		c.gotCommand()
+		return err
+	}
+
+	_, err := c.takeOver(time.Duration(displayTime)*time.Millisecond, show)
+	return err
+}
+
+// takeOver shows something on the pairing layout in place of what's being shown, until
+// d is up or (if d is 0) endTemporaryDisplay is called.
+func (c *LedController) takeOver(d time.Duration, show func() error) (*temporaryDisplay, error) {
+	c.displayLock.Lock()
+	defer c.displayLock.Unlock()
+
+	t := &temporaryDisplay{
+		previous: c.currentDisplay(),
+	}
+	c.temporaryDisplays = append(c.temporaryDisplays, t)
+
+	err := show()
+
+	c.setControlEnabled(false)
+	c.controlRequested = false
+	c.gotCommand()
+
+	if d > 0 {
+		t.timer = time.AfterFunc(d, func() {
+			c.endTemporaryDisplay(t)
+		})
+	}
+
+	return t, err
+}
+
+// endTemporaryDisplay goes back to what was shown before t, if t is the newest
+// temporary display. If it isn't, the next one goes back to that instead, when it ends.
+func (c *LedController) endTemporaryDisplay(t *temporaryDisplay) {
+	c.displayLock.Lock()
+	defer c.displayLock.Unlock()
+
+	for i, display := range c.temporaryDisplays {
+		if display != t {
+			continue
+		}
+
+		if i == len(c.temporaryDisplays)-1 {
+			log.Infof("Temporary display finished, restoring the previous display")
+			c.restoreDisplay(t.previous)
+		} else {
+			c.temporaryDisplays[i+1].previous = t.previous
+		}
+
+		c.temporaryDisplays = append(c.temporaryDisplays[:i], c.temporaryDisplays[i+1:]...)
+		return
+	}
+}
+
+// isTemporaryDisplay answers whether t is still being shown, or waiting to end.
+func (c *LedController) isTemporaryDisplay(t *temporaryDisplay) bool {
+	c.displayLock.Lock()
+	defer c.displayLock.Unlock()
+
+	for _, display := range c.temporaryDisplays {
+		if display == t {
+			return true
+		}
+	}
+	return false
+}
+
+// clearTemporaryDisplays forgets the displays shown for a while, as something has been
+// shown in their place until further notice.
+func (c *LedController) clearTemporaryDisplays() {
+	c.displayLock.Lock()
+	defer c.displayLock.Unlock()
+
+	for _, t := range c.temporaryDisplays {
+		if t.timer != nil {
+			t.timer.Stop()
+		}
+	}
+	c.temporaryDisplays = nil
+}
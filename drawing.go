@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"image"
+
+	"github.com/lucasb-eyer/go-colorful"
+	ledmodel "github.com/ninjasphere/sphere-go-led-controller/model"
+	"github.com/ninjasphere/sphere-go-led-controller/util"
+)
+
+// DisplayDrawing shows a blank drawing, which shows everything drawn on it straight away.
+func (c *LedController) DisplayDrawing() error {
+	return c.StartDrawing(&ledmodel.DrawingRequest{})
+}
+
+// StartDrawing shows a blank drawing. If it's double buffered, nothing drawn on it is
+// shown until commitDrawing is called.
+func (c *LedController) StartDrawing(req *ledmodel.DrawingRequest) error {
+	c.clearTemporaryDisplays()
+	c.pairingLayout.ShowNewDrawing(req.DoubleBuffered)
+	return nil
+}
+
+// Draw sets pixels of the drawing from x,y,r,g,b tuples.
+func (c *LedController) Draw(updates *[][]uint8) error {
+	return c.pairingLayout.Draw(updates)
+}
+
+// DrawFrame replaces the whole drawing with base64 encoded RGB pixels.
+func (c *LedController) DrawFrame(req *ledmodel.FrameRequest) error {
+	drawing, err := c.pairingLayout.Drawing()
+	if err != nil {
+		return err
+	}
+
+	rgb, err := base64.StdEncoding.DecodeString(req.Frame)
+	if err != nil {
+		return fmt.Errorf("Bad frame: %s", err)
+	}
+
+	return drawing.SetFrame(rgb)
+}
+
+func (c *LedController) FillRect(req *ledmodel.RectRequest) error {
+	drawing, err := c.pairingLayout.Drawing()
+	if err != nil {
+		return err
+	}
+
+	col, err := colorful.Hex(req.Color)
+	if err != nil {
+		return fmt.Errorf("Bad color %s: %s", req.Color, err)
+	}
+
+	return drawing.FillRect(image.Rect(req.X, req.Y, req.X+req.Width, req.Y+req.Height), col)
+}
+
+func (c *LedController) DrawLine(req *ledmodel.LineRequest) error {
+	drawing, err := c.pairingLayout.Drawing()
+	if err != nil {
+		return err
+	}
+
+	col, err := colorful.Hex(req.Color)
+	if err != nil {
+		return fmt.Errorf("Bad color %s: %s", req.Color, err)
+	}
+
+	return drawing.Line(image.Pt(req.X1, req.Y1), image.Pt(req.X2, req.Y2), col)
+}
+
+// DrawImage draws (the current frame of) an image from the images directory over the
+// drawing, with its top left corner at x,y.
+func (c *LedController) DrawImage(req *ledmodel.DrawImageRequest) error {
+	drawing, err := c.pairingLayout.Drawing()
+	if err != nil {
+		return err
+	}
+
+	img, err := util.OpenImage(util.ResolveImagePath(req.Image))
+	if err != nil {
+		return fmt.Errorf("Failed to open image %s: %s", req.Image, err)
+	}
+
+	drawing.DrawImage(img.GetNextFrame(), image.Pt(req.X, req.Y))
+	return nil
+}
+
+func (c *LedController) ClearDrawing() error {
+	drawing, err := c.pairingLayout.Drawing()
+	if err != nil {
+		return err
+	}
+
+	drawing.Clear()
+	return nil
+}
+
+// CommitDrawing shows everything drawn on a double buffered drawing since it was last
+// committed.
+func (c *LedController) CommitDrawing() error {
+	drawing, err := c.pairingLayout.Drawing()
+	if err != nil {
+		return err
+	}
+
+	drawing.Commit()
+	return nil
+}
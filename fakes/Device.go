@@ -0,0 +1,139 @@
+package fakes
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/ninjasphere/go-ninja/bus"
+	"github.com/ninjasphere/go-ninja/logger"
+)
+
+var log = logger.GetLogger("fakes")
+
+// Call is a method called on a device.
+type Call struct {
+	Method string
+	Args   interface{}
+}
+
+// Device is an in-memory device channel. It remembers the methods called on it, and
+// passes the events it is told to emit to the panes listening for them.
+type Device struct {
+	sync.Mutex
+	Topic string
+
+	// Reply, if set, answers the reply to a method call, which is copied into the
+	// caller's reply (via json, as it would be over the bus).
+	Reply func(method string, args interface{}) (interface{}, error)
+
+	calls    []Call
+	handlers map[string][]interface{}
+}
+
+func NewDevice(topic string) *Device {
+	return &Device{
+		Topic:    topic,
+		handlers: make(map[string][]interface{}),
+	}
+}
+
+func (d *Device) Call(method string, args interface{}, reply interface{}, timeout time.Duration) error {
+	log.Debugf("%s: %s %v", d.Topic, method, args)
+
+	d.Lock()
+	d.calls = append(d.calls, Call{method, args})
+	answer := d.Reply
+	d.Unlock()
+
+	if answer == nil {
+		return nil
+	}
+
+	result, err := answer(method, args)
+	if err != nil || reply == nil || result == nil {
+		return err
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, reply)
+}
+
+// OnEvent listens for an event, with a callback like those given to
+// ninja.ServiceClient.OnEvent: func(payload *T, topicKeys map[string]string) bool.
+func (d *Device) OnEvent(event string, callback interface{}) (*bus.Subscription, error) {
+	if err := checkCallback(callback); err != nil {
+		return nil, err
+	}
+
+	d.Lock()
+	defer d.Unlock()
+	d.handlers[event] = append(d.handlers[event], callback)
+
+	return &bus.Subscription{}, nil
+}
+
+func checkCallback(callback interface{}) error {
+	t := reflect.TypeOf(callback)
+	if t == nil || t.Kind() != reflect.Func || t.NumIn() != 2 || t.In(0).Kind() != reflect.Ptr {
+		return fmt.Errorf("Event callbacks must be func(*T, map[string]string) bool, not %v", t)
+	}
+	return nil
+}
+
+// Emit sends an event to everything listening for it. The payload is sent as json, so
+// each listener gets it as the type it asked for.
+func (d *Device) Emit(event string, payload interface{}) error {
+	return d.EmitWithKeys(event, payload, nil)
+}
+
+// EmitWithKeys emits an event with the values of the keys in a topic pattern, e.g.
+// {"deviceId": "..."} for $device/:deviceId.
+func (d *Device) EmitWithKeys(event string, payload interface{}, topicKeys map[string]string) error {
+	if topicKeys == nil {
+		topicKeys = map[string]string{}
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	d.Lock()
+	handlers := append([]interface{}{}, d.handlers[event]...)
+	d.Unlock()
+
+	for _, handler := range handlers {
+		callback := reflect.ValueOf(handler)
+
+		value := reflect.New(callback.Type().In(0).Elem())
+		if err := json.Unmarshal(data, value.Interface()); err != nil {
+			return fmt.Errorf("Failed to decode %s event for %s: %s", event, d.Topic, err)
+		}
+
+		callback.Call([]reflect.Value{value, reflect.ValueOf(topicKeys)})
+	}
+
+	return nil
+}
+
+// Calls answers the methods called so far.
+func (d *Device) Calls() []Call {
+	d.Lock()
+	defer d.Unlock()
+	return append([]Call{}, d.calls...)
+}
+
+// Methods answers the names of the methods called so far, in order.
+func (d *Device) Methods() []string {
+	var methods []string
+	for _, call := range d.Calls() {
+		methods = append(methods, call.Method)
+	}
+	return methods
+}
@@ -0,0 +1,131 @@
+package fakes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/ninjasphere/go-ninja/model"
+)
+
+// Fixture is a home loaded from a json file, so the controller can be run without the
+// mqtt bus:
+//
+//   {
+//     "site": {"id": "site", "latitude": -33.86, "longitude": 151.21, "timeZoneId": "Australia/Sydney"},
+//     "things": [
+//       {"id": "lamp", "type": "light", "name": "Lamp", "device": {"id": "lamp", "channels": [
+//         {"id": "on-off", "protocol": "on-off"}
+//       ]}}
+//     ],
+//     "events": [
+//       {"after": "10s", "topic": "lamp/on-off", "event": "state", "payload": true}
+//     ]
+//   }
+//
+// Things are as answered by the ThingModel's fetchAll. Things without a location are
+// put in Room, and channels without a topic are given the topic id/protocol (where id
+// is the thing's). Events are emitted in order, each after the one before. An event can
+// add a thing instead, with {"after": "5s", "add": {...}}.
+type Fixture struct {
+	Site   *model.Site    `json:"site"`
+	Things []model.Thing  `json:"things"`
+	Events []FixtureEvent `json:"events"`
+}
+
+// FixtureEvent is something that happens in a fixture's home.
+type FixtureEvent struct {
+	After     string            `json:"after"`
+	Topic     string            `json:"topic"`
+	Event     string            `json:"event"`
+	Payload   *json.RawMessage  `json:"payload"`
+	TopicKeys map[string]string `json:"values"`
+	Add       *model.Thing      `json:"add"`
+
+	wait time.Duration
+}
+
+// LoadFixture reads a fixture from the json file at path.
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fixture := &Fixture{}
+	if err := json.Unmarshal(data, fixture); err != nil {
+		return nil, fmt.Errorf("Failed to parse fixture %s: %s", path, err)
+	}
+
+	for i := range fixture.Events {
+		event := &fixture.Events[i]
+
+		if event.After != "" {
+			if event.wait, err = time.ParseDuration(event.After); err != nil {
+				return nil, fmt.Errorf("Bad wait before event %d in %s: %s", i+1, path, err)
+			}
+		}
+
+		if event.Add == nil && (event.Topic == "" || event.Event == "") {
+			return nil, fmt.Errorf("Event %d in %s needs a topic and an event, or a thing to add", i+1, path)
+		}
+	}
+
+	return fixture, nil
+}
+
+// Home answers a home with the fixture's site and things.
+func (f *Fixture) Home() *Home {
+	h := NewHome()
+	h.SetSite(f.Site)
+
+	for _, thing := range f.Things {
+		h.Add(placed(thing))
+	}
+
+	return h
+}
+
+// Play emits the fixture's events in the home, waiting before each.
+func (f *Fixture) Play(h *Home) {
+	for _, event := range f.Events {
+		time.Sleep(event.wait)
+
+		if event.Add != nil {
+			log.Infof("Adding %s", event.Add.ID)
+			h.Add(placed(*event.Add))
+			continue
+		}
+
+		log.Infof("%s: %s event", event.Topic, event.Event)
+
+		var payload interface{}
+		if event.Payload != nil {
+			payload = event.Payload
+		}
+
+		if err := h.DeviceFor(event.Topic).EmitWithKeys(event.Event, payload, event.TopicKeys); err != nil {
+			log.Warningf("Failed to emit %s event: %s", event.Event, err)
+		}
+	}
+}
+
+// placed answers a thing in Room if it doesn't have a location, with topics for any
+// channels without one.
+func placed(thing model.Thing) model.Thing {
+	if thing.Location == nil {
+		room := Room
+		thing.Location = &room
+	}
+
+	if thing.Device != nil && thing.Device.Channels != nil {
+		for _, channel := range *thing.Device.Channels {
+			if channel.Topic == "" {
+				channel.Topic = thing.ID + "/" + channel.Protocol
+			}
+		}
+	}
+
+	return thing
+}
@@ -0,0 +1,154 @@
+// Package fakes has in-memory stand ins for the ThingModel and the devices on the mqtt
+// bus, so panes can be run without a Sphere or a network.
+package fakes
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ninjasphere/go-ninja/config"
+	"github.com/ninjasphere/go-ninja/model"
+	"github.com/ninjasphere/sphere-go-led-controller/ui"
+)
+
+// Room is the room this Sphere is in. Panes only use devices in the same room.
+const Room = "room"
+
+// Home is an in-memory ui.Home.
+type Home struct {
+	sync.Mutex
+	things   []model.Thing
+	site     *model.Site
+	devices  map[string]*Device
+	onChange []func()
+}
+
+// NewHome answers a home with nothing in it but this Sphere, in Room.
+func NewHome() *Home {
+	h := &Home{
+		devices: make(map[string]*Device),
+	}
+
+	room := Room
+	h.things = append(h.things, model.Thing{
+		ID:       "sphere",
+		Type:     "node",
+		Name:     "Sphere",
+		Location: &room,
+		Device: &model.Device{
+			ID:        "sphere",
+			NaturalID: config.Serial(),
+		},
+	})
+
+	return h
+}
+
+// Add puts a thing in the home, with its channels, and tells the panes.
+func (h *Home) Add(thing model.Thing) {
+	h.Lock()
+	h.things = append(h.things, thing)
+	onChange := append([]func(){}, h.onChange...)
+	h.Unlock()
+
+	for _, changed := range onChange {
+		changed()
+	}
+}
+
+// AddThing puts a thing of thingType in Room, with a channel for each protocol. The
+// channels' topics are id/protocol.
+func (h *Home) AddThing(id string, thingType string, protocols ...string) {
+	var channels []*model.Channel
+	for _, protocol := range protocols {
+		channel := &model.Channel{
+			ID:       protocol,
+			Protocol: protocol,
+		}
+		channel.Topic = id + "/" + protocol
+		channels = append(channels, channel)
+	}
+
+	room := Room
+	h.Add(model.Thing{
+		ID:       id,
+		Type:     thingType,
+		Name:     id,
+		Location: &room,
+		Device: &model.Device{
+			ID:       id,
+			Channels: &channels,
+		},
+	})
+}
+
+// Things answers everything in the home.
+func (h *Home) Things() []model.Thing {
+	h.Lock()
+	defer h.Unlock()
+	return append([]model.Thing{}, h.things...)
+}
+
+// DeviceFor answers the device for a channel's topic, which is made the first time
+// it's needed.
+func (h *Home) DeviceFor(topic string) *Device {
+	h.Lock()
+	defer h.Unlock()
+
+	device, ok := h.devices[topic]
+	if !ok {
+		device = NewDevice(topic)
+		h.devices[topic] = device
+	}
+	return device
+}
+
+func (h *Home) FetchAll() ([]model.Thing, error) {
+	return h.Things(), nil
+}
+
+func (h *Home) Device(channel *model.Channel) *ui.Device {
+	var methods []string
+	if channel.SupportedMethods != nil {
+		methods = *channel.SupportedMethods
+	}
+
+	return &ui.Device{
+		DeviceClient:     h.DeviceFor(channel.Topic),
+		Topic:            channel.Topic,
+		SupportedMethods: methods,
+	}
+}
+
+func (h *Home) OnChange(changed func()) {
+	h.Lock()
+	defer h.Unlock()
+	h.onChange = append(h.onChange, changed)
+}
+
+// SetSite sets the site answered by FetchSite.
+func (h *Home) SetSite(site *model.Site) {
+	h.Lock()
+	defer h.Unlock()
+	h.site = site
+}
+
+// Paired answers true, as the home's things are there from the start.
+func (h *Home) Paired() bool {
+	return true
+}
+
+func (h *Home) FetchSite() (*model.Site, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.site == nil {
+		return nil, fmt.Errorf("The home doesn't have a site")
+	}
+	site := *h.site
+	return &site, nil
+}
+
+func (h *Home) Service(topic string) ui.DeviceClient {
+	return h.DeviceFor(topic)
+}
@@ -0,0 +1,93 @@
+package gestures
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ninjasphere/gestic-tools/go-gestic-sdk"
+)
+
+// ReplaySource plays back gestures recorded with --led.gestures.log, which writes each
+// gesture to stdout as a line of json. Lines that aren't json objects (e.g. log
+// messages written to the same file) are skipped.
+//
+// The gestures are played with the time between them they were recorded with, but are
+// sent with the current time.
+type ReplaySource struct {
+	Path string
+	Loop bool // start again from the first gesture after the last
+}
+
+func (s *ReplaySource) Gestures() (<-chan *gestic.GestureMessage, error) {
+	recorded, err := readRecording(s.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Replaying %d gestures from %s", len(recorded), s.Path)
+
+	gestures := make(chan *gestic.GestureMessage)
+
+	go func() {
+		for {
+			for i, g := range recorded {
+				if i > 0 {
+					if wait := g.Time.Sub(recorded[i-1].Time); wait > 0 {
+						time.Sleep(wait)
+					}
+				}
+
+				replayed := *g
+				replayed.Time = time.Now()
+				gestures <- &replayed
+			}
+
+			if !s.Loop {
+				break
+			}
+		}
+
+		log.Infof("Finished replaying gestures from %s", s.Path)
+		close(gestures)
+	}()
+
+	return gestures, nil
+}
+
+func readRecording(path string) ([]*gestic.GestureMessage, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open gesture recording: %s", err)
+	}
+	defer file.Close()
+
+	var recorded []*gestic.GestureMessage
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 || text[0] != '{' {
+			continue
+		}
+
+		var g gestic.GestureMessage
+		if err := json.Unmarshal(text, &g); err != nil {
+			return nil, fmt.Errorf("Bad gesture on line %d of %s: %s", line, path, err)
+		}
+		recorded = append(recorded, &g)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to read gesture recording %s: %s", path, err)
+	}
+
+	if len(recorded) == 0 {
+		return nil, fmt.Errorf("No gestures in %s", path)
+	}
+
+	return recorded, nil
+}
@@ -0,0 +1,162 @@
+package gestures
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ninjasphere/gestic-tools/go-gestic-sdk"
+)
+
+// The time between steps of a script that don't wait themselves, so the panes see
+// each gesture as a separate one.
+const scriptStepInterval = 500 * time.Millisecond
+
+// Script is a list of gestures to make, and pauses between them.
+type Script []ScriptStep
+
+// ScriptStep is either a gesture, or a pause.
+type ScriptStep struct {
+	Action *Action
+	Wait   time.Duration
+}
+
+// LoadScript reads a script file. See ParseScript.
+func LoadScript(path string) (Script, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open gesture script: %s", err)
+	}
+	defer file.Close()
+
+	script, err := ParseScript(file)
+	if err != nil {
+		return nil, fmt.Errorf("Bad gesture script %s: %s", path, err)
+	}
+	return script, nil
+}
+
+// ParseScript reads a script with a step on each line, e.g.
+//
+//	# Turn the volume up, then back down
+//	wait 2s
+//	tap
+//	airWheel 60
+//	wait 1s
+//	airWheel -60
+//	flickEastToWest
+//
+// Each gesture is followed by a short pause unless the next step is a wait. Blank lines
+// and lines starting with # are ignored.
+func ParseScript(r io.Reader) (Script, error) {
+	var script Script
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		step, err := parseStep(fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		script = append(script, step)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(script) == 0 {
+		return nil, fmt.Errorf("no steps")
+	}
+
+	return script, nil
+}
+
+func parseStep(fields []string) (ScriptStep, error) {
+	name, args := fields[0], fields[1:]
+
+	if name == "wait" {
+		if len(args) != 1 {
+			return ScriptStep{}, fmt.Errorf("wait needs a duration, e.g. wait 500ms")
+		}
+		wait, err := time.ParseDuration(args[0])
+		if err != nil || wait < 0 {
+			return ScriptStep{}, fmt.Errorf("bad duration: %s", args[0])
+		}
+		return ScriptStep{Wait: wait}, nil
+	}
+
+	action := &Action{Gesture: name}
+
+	if name == "airWheel" {
+		if len(args) != 1 {
+			return ScriptStep{}, fmt.Errorf("airWheel needs the distance to turn, e.g. airWheel -20")
+		}
+		delta, err := strconv.Atoi(args[0])
+		if err != nil {
+			return ScriptStep{}, fmt.Errorf("bad airWheel distance: %s", args[0])
+		}
+		action.Delta = delta
+	} else if len(args) != 0 {
+		return ScriptStep{}, fmt.Errorf("%s doesn't take any arguments", name)
+	}
+
+	if err := action.Validate(); err != nil {
+		return ScriptStep{}, err
+	}
+
+	return ScriptStep{Action: action}, nil
+}
+
+// ScriptSource makes the gestures in a script.
+type ScriptSource struct {
+	Script Script
+	Loop   bool // start again from the first step after the last
+}
+
+func (s *ScriptSource) Gestures() (<-chan *gestic.GestureMessage, error) {
+	if len(s.Script) == 0 {
+		return nil, fmt.Errorf("The gesture script has no steps")
+	}
+
+	gestures := make(chan *gestic.GestureMessage)
+	wheel := NewAirWheel()
+	send := func(g *gestic.GestureMessage) {
+		gestures <- g
+	}
+
+	go func() {
+		for {
+			for i, step := range s.Script {
+				if step.Action == nil {
+					time.Sleep(step.Wait)
+					continue
+				}
+
+				step.Action.Perform(wheel, send)
+
+				if i+1 < len(s.Script) && s.Script[i+1].Action == nil {
+					continue
+				}
+				time.Sleep(scriptStepInterval)
+			}
+
+			if !s.Loop {
+				break
+			}
+		}
+
+		log.Infof("Finished the gesture script")
+		close(gestures)
+	}()
+
+	return gestures, nil
+}
@@ -0,0 +1,108 @@
+package gestures
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ninjasphere/gestic-tools/go-gestic-sdk"
+	"github.com/ninjasphere/go-ninja/config"
+	"github.com/ninjasphere/go-ninja/logger"
+)
+
+var log = logger.GetLogger("gestures")
+
+var replayFile = config.String("gestures.log", "led.gestures.replay.file")
+var scriptFile = config.String("gestures.script", "led.gestures.script.file")
+var loop = config.Bool(false, "led.gestures.loop")
+
+// Source is somewhere gestures come from: the GestIC chip, or a stand in for it.
+type Source interface {
+	// Gestures starts the source, answering a channel of its gestures that is closed
+	// once there are no more.
+	Gestures() (<-chan *gestic.GestureMessage, error)
+}
+
+var sources = map[string]func() (Source, error){
+	"gestic": func() (Source, error) {
+		return &GesticSource{}, nil
+	},
+	"replay": func() (Source, error) {
+		return &ReplaySource{
+			Path: replayFile,
+			Loop: loop,
+		}, nil
+	},
+	"script": func() (Source, error) {
+		script, err := LoadScript(scriptFile)
+		if err != nil {
+			return nil, err
+		}
+		return &ScriptSource{
+			Script: script,
+			Loop:   loop,
+		}, nil
+	},
+	"none": func() (Source, error) {
+		return &noSource{}, nil
+	},
+}
+
+// RegisterSource makes a gesture source available to NewSource.
+func RegisterSource(name string, factory func() (Source, error)) {
+	sources[name] = factory
+}
+
+// Sources answers the names of the registered gesture sources.
+func Sources() []string {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewSource answers an unstarted gesture source by name.
+func NewSource(name string) (Source, error) {
+	factory, ok := sources[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown gesture source '%s'. Available: %v", name, Sources())
+	}
+	return factory()
+}
+
+// GesticSource answers the gestures seen by the GestIC chip.
+type GesticSource struct {
+}
+
+func (s *GesticSource) Gestures() (<-chan *gestic.GestureMessage, error) {
+	device, err := gestic.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Succesfully connected to GestIC device")
+
+	gestures := make(chan *gestic.GestureMessage)
+
+	go func() {
+		for gesture := range device.DataStream() {
+			// Copied, as the stream answers values
+			g := gesture
+			gestures <- &g
+		}
+		close(gestures)
+	}()
+
+	return gestures, nil
+}
+
+// noSource never has any gestures.
+type noSource struct {
+}
+
+func (s *noSource) Gestures() (<-chan *gestic.GestureMessage, error) {
+	gestures := make(chan *gestic.GestureMessage)
+	close(gestures)
+	return gestures, nil
+}
@@ -0,0 +1,162 @@
+// Package gestures makes gesture messages that weren't seen by the GestIC chip, so the
+// panes can be driven without a Sphere (from the live preview, or a script).
+package gestures
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ninjasphere/gestic-tools/go-gestic-sdk"
+	"github.com/ninjasphere/sphere-go-led-controller/util"
+)
+
+// The furthest the airwheel counter moves in one message. Panes treat bigger jumps as
+// the counter wrapping around.
+const maxAirWheelStep = 20
+
+// The time between the messages of an airwheel turn, well inside the time panes wait
+// before forgetting the last counter.
+const airWheelInterval = 50 * time.Millisecond
+
+// The furthest an Action can turn the airwheel
+const MaxAirWheelDelta = 255
+
+// Action is a gesture by name, as used in scripts and sent by the live preview.
+// Gesture is one of flickEastToWest, flickWestToEast, tap, doubleTap or airWheel
+// (which turns the airwheel by Delta).
+type Action struct {
+	Gesture string `json:"gesture"`
+	Delta   int    `json:"delta,omitempty"`
+}
+
+var namedGestures = map[string]func() *gestic.GestureMessage{
+	"flickEastToWest": FlickEastToWest,
+	"flickWestToEast": FlickWestToEast,
+	"tap":             Tap,
+	"doubleTap":       DoubleTap,
+}
+
+// Validate answers an error if the action can't be performed.
+func (a *Action) Validate() error {
+	if a.Gesture == "airWheel" {
+		if a.Delta == 0 || a.Delta > MaxAirWheelDelta || a.Delta < -MaxAirWheelDelta {
+			return fmt.Errorf("airWheel delta must be between -%d and %d, and not 0", MaxAirWheelDelta, MaxAirWheelDelta)
+		}
+		return nil
+	}
+
+	if _, ok := namedGestures[a.Gesture]; !ok {
+		return fmt.Errorf("Unknown gesture: %s", a.Gesture)
+	}
+	return nil
+}
+
+// Perform sends the gestures for a valid action. Turning the airwheel takes a few
+// messages, so doesn't return until it's finished.
+func (a *Action) Perform(wheel *AirWheel, send func(*gestic.GestureMessage)) {
+	if a.Gesture == "airWheel" {
+		wheel.Turn(a.Delta, send)
+		return
+	}
+
+	if gesture, ok := namedGestures[a.Gesture]; ok {
+		send(gesture())
+	}
+}
+
+// FlickEastToWest answers a flick to the next pane.
+func FlickEastToWest() *gestic.GestureMessage {
+	g := message()
+	g.Gesture.Gesture = gestic.GestureFlickEastToWest
+	return g
+}
+
+// FlickWestToEast answers a flick to the previous pane.
+func FlickWestToEast() *gestic.GestureMessage {
+	g := message()
+	g.Gesture.Gesture = gestic.GestureFlickWestToEast
+	return g
+}
+
+// Tap answers a tap on the centre of the Sphere.
+func Tap() *gestic.GestureMessage {
+	g := message()
+	g.Tap.Center = true
+	return g
+}
+
+// DoubleTap answers a double tap on the centre of the Sphere.
+func DoubleTap() *gestic.GestureMessage {
+	g := message()
+	g.DoubleTap.Center = true
+	return g
+}
+
+func message() *gestic.GestureMessage {
+	return &gestic.GestureMessage{
+		Time: util.DefaultClock.Now(),
+	}
+}
+
+// AirWheel keeps a counter like the GestIC chip's, which goes up as a finger circles
+// clockwise and down as it circles anticlockwise, wrapping between 1 and 255.
+type AirWheel struct {
+	sync.Mutex
+	counter int
+
+	// Sleep waits between the messages of a turn. It can be replaced to turn the
+	// wheel by a fake clock.
+	Sleep func(time.Duration)
+}
+
+func NewAirWheel() *AirWheel {
+	return &AirWheel{
+		counter: 128,
+		Sleep:   time.Sleep,
+	}
+}
+
+// Turn sends the messages for moving the counter by delta to send, one every
+// airWheelInterval. The first message only tells the panes where the counter starts.
+func (w *AirWheel) Turn(delta int, send func(*gestic.GestureMessage)) {
+	w.Lock()
+	defer w.Unlock()
+
+	sinceLast := 0
+	step := func(by int) {
+		w.counter = wrapCounter(w.counter + by)
+
+		g := message()
+		g.AirWheel.Active = true
+		g.AirWheel.Counter = w.counter
+		g.AirWheel.CountSinceLast = sinceLast
+		send(g)
+
+		sinceLast++
+	}
+
+	step(0)
+	for delta != 0 {
+		w.Sleep(airWheelInterval)
+
+		by := delta
+		if by > maxAirWheelStep {
+			by = maxAirWheelStep
+		} else if by < -maxAirWheelStep {
+			by = -maxAirWheelStep
+		}
+		step(by)
+		delta -= by
+	}
+}
+
+func wrapCounter(counter int) int {
+	for counter > 255 {
+		counter -= 255
+	}
+	for counter < 1 {
+		counter += 255
+	}
+	return counter
+}
@@ -0,0 +1,78 @@
+package harness
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// ErrNoGolden is answered when there is no golden image to compare with yet.
+var ErrNoGolden = errors.New("no golden frames")
+
+// Strip answers the frames side by side, as they're stored in golden images.
+func Strip(frames []*image.RGBA) *image.RGBA {
+	strip := image.NewRGBA(image.Rect(0, 0, 16*len(frames), 16))
+	for i, frame := range frames {
+		draw.Draw(strip, image.Rect(i*16, 0, i*16+16, 16), frame, frame.Bounds().Min, draw.Src)
+	}
+	return strip
+}
+
+// CompareGolden compares frames with the golden strip in the png at path. If update is
+// set, the golden strip is rewritten with the frames instead.
+func CompareGolden(path string, frames []*image.RGBA, update bool) error {
+	strip := Strip(frames)
+
+	if update {
+		return writeGolden(path, strip)
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return ErrNoGolden
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	decoded, err := png.Decode(f)
+	if err != nil {
+		return fmt.Errorf("Bad golden frames %s: %s", path, err)
+	}
+
+	golden := image.NewRGBA(decoded.Bounds())
+	draw.Draw(golden, golden.Bounds(), decoded, decoded.Bounds().Min, draw.Src)
+
+	if golden.Rect.Dx() != strip.Rect.Dx() || golden.Rect.Dy() != strip.Rect.Dy() {
+		return fmt.Errorf("Rendered %d frames, but there are %d golden frames", len(frames), golden.Rect.Dx()/16)
+	}
+
+	for y := 0; y < strip.Rect.Dy(); y++ {
+		for x := 0; x < strip.Rect.Dx(); x++ {
+			if strip.RGBAAt(x, y) != golden.RGBAAt(x, y) {
+				return fmt.Errorf("Frame %d differs at (%d,%d): got %v, golden %v", x/16, x%16, y, strip.RGBAAt(x, y), golden.RGBAAt(x, y))
+			}
+		}
+	}
+
+	return nil
+}
+
+func writeGolden(path string, strip *image.RGBA) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	return png.Encode(out, strip)
+}
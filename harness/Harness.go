@@ -0,0 +1,152 @@
+// Package harness runs panes without a Sphere: on a fake clock, with fake devices and
+// synthetic gestures, so the frames they render can be compared with golden images.
+//
+// The clock, the home and the pane settings are shared by every pane, so only one
+// harness can be used at a time. The settings are pinned (rather than read from the
+// sphere's config), so panes render the same frames on any machine. Pane timers (e.g.
+// the alarm on the clock pane) still use the wall clock.
+package harness
+
+import (
+	"image"
+	"image/draw"
+	"time"
+
+	"github.com/ninjasphere/gestic-tools/go-gestic-sdk"
+	"github.com/ninjasphere/sphere-go-led-controller/fakes"
+	"github.com/ninjasphere/sphere-go-led-controller/gestures"
+	"github.com/ninjasphere/sphere-go-led-controller/ui"
+	"github.com/ninjasphere/sphere-go-led-controller/util"
+)
+
+// FrameInterval is the time between frames rendered by the harness.
+const FrameInterval = time.Second / 30
+
+// Epoch is the time each harness starts at, in the local time zone so the clock pane
+// shows 10:04.
+var Epoch = time.Date(2015, time.June, 1, 10, 4, 0, 0, time.Local)
+
+// Renderer is anything that renders frames, e.g. a ui.Pane or ui.PairingLayout.
+type Renderer interface {
+	Render() (*image.RGBA, error)
+}
+
+// Renderers implementing dirtyRenderer know whether their next frame may be different
+// to the last one, so don't need rendering again until it may be.
+type dirtyRenderer interface {
+	IsDirty() bool
+}
+
+type Harness struct {
+	Clock *util.ManualClock
+	Home  *fakes.Home
+
+	airWheel *gestures.AirWheel
+	shown    map[Renderer]*image.RGBA // the last frame rendered by each renderer
+}
+
+// New answers a harness at Epoch, with a home that is empty except for the Sphere. It
+// replaces the clock, home and settings used by the panes, so must be made before the
+// panes are.
+func New() *Harness {
+	h := &Harness{
+		Clock:    util.NewManualClock(Epoch),
+		Home:     fakes.NewHome(),
+		airWheel: gestures.NewAirWheel(),
+		shown:    make(map[Renderer]*image.RGBA),
+	}
+	h.airWheel.Sleep = h.Clock.Advance
+
+	util.SetDefaultClock(h.Clock)
+	util.SetDefaultFitMode(util.FitArea)
+	ui.SetHome(h.Home)
+	ui.SetPaneSettings(settings())
+
+	return h
+}
+
+// settings answers the led.* config the panes are pinned to, in place of the sphere's
+// config, so they render the same frames on any machine.
+func settings() ui.PaneSettings {
+	return ui.PaneSettings{
+		ClockAlarm:         true,
+		ClockFlashTimes:    2,
+		ClockFlashInterval: time.Millisecond * 500,
+
+		OnOffTapTimeout: time.Millisecond * 500,
+
+		LightTapInterval:       time.Millisecond * 500,
+		LightColorInterval:     time.Millisecond * 50,
+		LightColorSpeed:        1,
+		LightBrightnessSpeed:   1,
+		LightBrightnessMinimum: 20,
+
+		MediaVolumeModeReset: time.Second * 3,
+		MediaTapInterval:     time.Second,
+		MediaVolumeInterval:  time.Millisecond * 100,
+		MediaAirWheelReset:   time.Millisecond * 500,
+		MediaImages: ui.MediaPaneImages{
+			Volume:     util.ResolveImagePath("media-volume-speaker.png"),
+			VolumeUp:   util.ResolveImagePath("media-volume-up.png"),
+			VolumeDown: util.ResolveImagePath("media-volume-down.png"),
+			Mute:       util.ResolveImagePath("media-volume-mute.png"),
+			Play:       util.ResolveImagePath("media-play.png"),
+			Pause:      util.ResolveImagePath("media-pause.png"),
+			Stop:       util.ResolveImagePath("media-stop.png"),
+			Next:       util.ResolveImagePath("media-next.png"),
+		},
+	}
+}
+
+// Refresh tells the panes about the things in the home. Panes made afterwards get
+// their devices straight away.
+func (h *Harness) Refresh() error {
+	return ui.RefreshThings()
+}
+
+// Advance moves the clock forward.
+func (h *Harness) Advance(d time.Duration) {
+	h.Clock.Advance(d)
+}
+
+// Gesture sends a gesture to a pane, at the time on the clock. Airwheel turns move the
+// clock on between each of their messages.
+func (h *Harness) Gesture(pane ui.Pane, action gestures.Action) error {
+	if err := action.Validate(); err != nil {
+		return err
+	}
+	action.Perform(h.airWheel, func(g *gestic.GestureMessage) {
+		pane.Gesture(g)
+	})
+	return nil
+}
+
+// Frames answers the n frames shown, moving the clock on by FrameInterval after each.
+// As in the controller's render loop, a renderer that isn't dirty isn't rendered again,
+// and its last frame stays on the display.
+func (h *Harness) Frames(r Renderer, n int) ([]*image.RGBA, error) {
+	var frames []*image.RGBA
+
+	for i := 0; i < n; i++ {
+		frame, ok := h.shown[r]
+		if d, dirtyable := r.(dirtyRenderer); !ok || !dirtyable || d.IsDirty() {
+			rendered, err := r.Render()
+			if err != nil {
+				return nil, err
+			}
+			frame = copyFrame(rendered)
+			h.shown[r] = frame
+		}
+		frames = append(frames, copyFrame(frame))
+		h.Clock.Advance(FrameInterval)
+	}
+
+	return frames, nil
+}
+
+// Copied, as panes can draw into the frames they answer
+func copyFrame(frame *image.RGBA) *image.RGBA {
+	out := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	draw.Draw(out, out.Bounds(), frame, frame.Bounds().Min, draw.Src)
+	return out
+}
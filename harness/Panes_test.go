@@ -0,0 +1,264 @@
+package harness
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ninjasphere/sphere-go-led-controller/fakes"
+	"github.com/ninjasphere/sphere-go-led-controller/gestures"
+	"github.com/ninjasphere/sphere-go-led-controller/ui"
+	"github.com/ninjasphere/sphere-go-led-controller/util"
+)
+
+// Each case drives a pane with the harness (a fake clock, fake devices, synthetic
+// gestures and pinned settings), and its golden image in testdata/panes is a strip of
+// the 16x16 frames it rendered. A case without a golden image fails. Write them with
+// 'go test ./harness -update', after an intended change or for a new case, and check them.
+var update = flag.Bool("update", false, "rewrite the golden frames")
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	// The tests are run from the package's directory
+	util.SetImageDir("../images")
+	os.Exit(m.Run())
+}
+
+// recording collects the frames of a case, stopping at the first error.
+type recording struct {
+	h      *Harness
+	frames []*image.RGBA
+	err    error
+}
+
+func (r *recording) render(pane Renderer, n int) {
+	if r.err != nil {
+		return
+	}
+	frames, err := r.h.Frames(pane, n)
+	r.frames = append(r.frames, frames...)
+	r.err = err
+}
+
+func (r *recording) gesture(pane ui.Pane, name string, delta int) {
+	if r.err != nil {
+		return
+	}
+	r.err = r.h.Gesture(pane, gestures.Action{Gesture: name, Delta: delta})
+}
+
+func (r *recording) emit(device *fakes.Device, event string, payload interface{}) {
+	if r.err != nil {
+		return
+	}
+	r.err = device.Emit(event, payload)
+}
+
+// expect checks the methods called on a device so far.
+func (r *recording) expect(device *fakes.Device, methods ...string) {
+	if r.err != nil {
+		return
+	}
+	if called := device.Methods(); !reflect.DeepEqual(called, methods) {
+		r.err = fmt.Errorf("expected %v to be called on %s, got %v", methods, device.Topic, called)
+	}
+}
+
+func (r *recording) enabled(pane ui.Pane) {
+	if r.err == nil && !pane.IsEnabled() {
+		r.err = fmt.Errorf("the pane isn't enabled")
+	}
+}
+
+type paneCase struct {
+	name string
+	run  func(r *recording)
+}
+
+var cases = []paneCase{
+	{"clock", func(r *recording) {
+		r.h.Home.AddThing("lamp", "light", "on-off")
+		r.err = r.h.Refresh()
+
+		pane := ui.NewClockPane()
+		r.render(pane, 1)
+		r.h.Advance(time.Second)
+		r.render(pane, 1)
+
+		// Tapping sets an alarm a minute away
+		r.gesture(pane, "tap", 0)
+		r.render(pane, 1)
+		r.h.Advance(30 * time.Second)
+		r.render(pane, 1)
+	}},
+	{"onoff", func(r *recording) {
+		r.h.Home.AddThing("lamp", "lamp", "on-off")
+		r.err = r.h.Refresh()
+		lamp := r.h.Home.DeviceFor("lamp/on-off")
+
+		pane := ui.NewOnOffPane(util.ResolveImagePath("lamp2-off.gif"), util.ResolveImagePath("lamp2-on.gif"), func(bool) {}, nil, "lamp")
+		r.enabled(pane)
+		r.render(pane, 4)
+
+		r.gesture(pane, "tap", 0)
+		r.expect(lamp, "turnOn")
+		r.render(pane, 4)
+
+		// Turned off somewhere else
+		r.h.Advance(2 * time.Second)
+		r.emit(lamp, "state", false)
+		r.render(pane, 1)
+	}},
+	{"light", func(r *recording) {
+		r.h.Home.AddThing("bulb", "light", "on-off", "core/batching", "brightness")
+		r.err = r.h.Refresh()
+		bulb := r.h.Home.DeviceFor("bulb/on-off")
+
+		pane := ui.NewLightPane(false, util.ResolveImagePath("light-off.png"), util.ResolveImagePath("light-on.png"), nil)
+		r.enabled(pane)
+		r.render(pane, 1)
+
+		r.h.Advance(2 * time.Second)
+		r.gesture(pane, "tap", 0)
+		r.expect(bulb, "turnOn")
+		r.render(pane, 1)
+
+		r.gesture(pane, "airWheel", 60)
+		r.render(pane, 1)
+		r.h.Advance(time.Second)
+		r.gesture(pane, "airWheel", -30)
+		r.render(pane, 1)
+	}},
+	{"light-color", func(r *recording) {
+		r.h.Home.AddThing("bulb", "light", "core/batching", "color")
+		r.err = r.h.Refresh()
+
+		pane := ui.NewLightPane(true, util.ResolveImagePath("light-off.png"), util.ResolveImagePath("light-on.png"), nil)
+		r.enabled(pane)
+		r.render(pane, 1)
+
+		r.gesture(pane, "airWheel", 60)
+		r.render(pane, 1)
+		r.h.Advance(time.Second)
+		r.gesture(pane, "airWheel", 120)
+		r.render(pane, 1)
+	}},
+	{"media", func(r *recording) {
+		r.h.Home.AddThing("speaker", "mediaplayer", "media-control", "volume")
+		r.err = r.h.Refresh()
+		control := r.h.Home.DeviceFor("speaker/media-control")
+
+		pane := ui.NewMediaPane(nil)
+		r.enabled(pane)
+		r.render(pane, 1)
+
+		r.emit(control, "playing", nil)
+		r.render(pane, 1)
+
+		r.gesture(pane, "tap", 0)
+		r.expect(control, "pause")
+		r.render(pane, 1)
+
+		// Turning the airwheel switches to the volume
+		r.gesture(pane, "airWheel", 40)
+		r.render(pane, 1)
+	}},
+	{"marquee", func(r *recording) {
+		pane, err := ui.NewMarqueePane("Hello Sphere\n12", ui.MarqueeOptions{Color: color.RGBA{0, 255, 255, 255}, Repeat: 1})
+		if err != nil {
+			r.err = err
+			return
+		}
+		r.render(pane, 1)
+		r.h.Advance(500 * time.Millisecond)
+		r.render(pane, 1)
+
+		// Stopped, after scrolling past once
+		r.h.Advance(pane.Duration())
+		r.render(pane, 1)
+	}},
+	{"pairing-icon", func(r *recording) {
+		layout := ui.NewPairingLayout()
+		r.err = layout.ShowIcon("spinner-blue.gif")
+		r.render(layout, 4)
+	}},
+	{"pairing-color", func(r *recording) {
+		layout := ui.NewPairingLayout()
+		layout.ShowColor(color.RGBA{0x51, 0x7A, 0xB8, 0xFF})
+		r.render(layout, 4)
+	}},
+	{"pairing-code", func(r *recording) {
+		layout := ui.NewPairingLayout()
+		layout.ShowCode("1234")
+		r.render(layout, 1)
+	}},
+	{"pairing-progress", func(r *recording) {
+		layout := ui.NewPairingLayout()
+		layout.ShowUpdateProgress(0.5)
+		r.render(layout, 1)
+	}},
+	{"pairing-fade", func(r *recording) {
+		layout := ui.NewPairingLayout()
+		layout.ShowFadingShrinkingColor(color.RGBA{0xFF, 0, 0, 0xFF}, time.Second)
+		r.render(layout, 1)
+		r.h.Advance(500 * time.Millisecond)
+		r.render(layout, 1)
+		r.h.Advance(600 * time.Millisecond)
+		r.render(layout, 1)
+	}},
+	{"pairing-drawing", func(r *recording) {
+		layout := ui.NewPairingLayout()
+		layout.ShowDrawing()
+		r.err = layout.Draw(&[][]uint8{{0, 0, 255, 0, 0}, {15, 15, 0, 0, 255}, {8, 4, 0, 255, 0}})
+		r.render(layout, 1)
+	}},
+	{"pairing-drawing-buffered", func(r *recording) {
+		layout := ui.NewPairingLayout()
+		layout.ShowNewDrawing(true)
+		drawing, _ := layout.Drawing()
+
+		r.err = drawing.FillRect(image.Rect(2, 2, 14, 14), color.RGBA{0, 0, 128, 255})
+		if r.err == nil {
+			r.err = drawing.Line(image.Pt(0, 15), image.Pt(15, 0), color.RGBA{255, 255, 0, 255})
+		}
+		// Nothing is shown until it's committed
+		r.render(layout, 1)
+		drawing.Commit()
+		r.render(layout, 1)
+
+		if r.err == nil && drawing.Line(image.Pt(0, 0), image.Pt(16, 0), color.White) == nil {
+			r.err = fmt.Errorf("a line off the display was drawn")
+		}
+		if r.err == nil && layout.Draw(&[][]uint8{{1, 1, 255, 255, 255}, {1, 16, 0, 0, 0}}) == nil {
+			r.err = fmt.Errorf("a pixel off the display was drawn")
+		}
+		drawing.Clear()
+		drawing.Commit()
+		r.render(layout, 1)
+	}},
+}
+
+func TestPanes(t *testing.T) {
+	for _, c := range cases {
+		r := &recording{h: New()}
+		c.run(r)
+		if r.err != nil {
+			t.Errorf("%s: %s", c.name, r.err)
+			continue
+		}
+
+		err := CompareGolden(filepath.Join("testdata/panes", c.name+".png"), r.frames, *update)
+		switch {
+		case err == ErrNoGolden:
+			t.Errorf("%s: no golden frames. Write them with -update, and check them.", c.name)
+		case err != nil:
+			t.Errorf("%s: %s", c.name, err)
+		}
+	}
+}
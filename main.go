@@ -6,31 +6,29 @@ import (
 
 	"github.com/ninjasphere/go-ninja/logger"
 
-	//"net/http"
-
 	"github.com/ninjasphere/go-ninja/api"
 	"github.com/ninjasphere/go-ninja/config"
 )
 
-//import _ "net/http/pprof"
-
 const drivername = "sphere-led-controller"
 
 func main() {
 
-	/*
-		go func() {
-			log.Printf("Starting pprof server")
-			log.Println(http.ListenAndServe(":6060", nil))
-		}()
-		//*/
-
 	log := logger.GetLogger("LED-controller")
 
-	conn, err := ninja.Connect(drivername)
+	var conn *ninja.Connection
+	var err error
 
-	if err != nil {
-		log.FatalErrorf(err, "Failed to connect to mqtt")
+	if offlineFixture != "" {
+		if err := startOffline(); err != nil {
+			log.FatalErrorf(err, "Failed to load offline fixture")
+		}
+	} else {
+		conn, err = ninja.Connect(drivername)
+
+		if err != nil {
+			log.FatalErrorf(err, "Failed to connect to mqtt")
+		}
 	}
 
 	controller, err := NewLedController(conn)
@@ -39,7 +37,22 @@ func main() {
 		log.FatalErrorf(err, "Failed to create led controller")
 	}
 
-	enableControl := config.Bool(false, "enableControl")
+	if enableDebugServer {
+		controller.startDebugServer()
+	}
+
+	if enablePreview {
+		controller.startPreview()
+	}
+
+	if enableStream {
+		if err := controller.startStream(); err != nil {
+			log.FatalErrorf(err, "Failed to start listening for frame streams")
+		}
+	}
+
+	// Offline, there's no homecloud to enable control
+	enableControl := config.Bool(offlineFixture != "", "enableControl")
 
 	controller.start(enableControl)
 
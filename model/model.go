@@ -14,7 +14,111 @@ type DisplayUpdateProgress struct {
 	Progress float64 `json:"progress"`
 }
 
+// The display time of IconRequest, ColorRequest and PairingCodeRequest is in
+// milliseconds. If it's 0 they're shown until something else is.
+
 type IconRequest struct {
 	Icon        string `json:"icon"`
 	DisplayTime int    `json:"displayTime"`
 }
+
+type ColorRequest struct {
+	Color       string `json:"color"`
+	DisplayTime int    `json:"displayTime"`
+}
+
+type PairingCodeRequest struct {
+	Code        string `json:"code"`
+	DisplayTime int    `json:"displayTime"`
+}
+
+type NotificationRequest struct {
+	Icon        string `json:"icon,omitempty"`
+	Text        string `json:"text,omitempty"`
+	Color       string `json:"color,omitempty"`
+	Priority    int    `json:"priority"`
+	DisplayTime int    `json:"displayTime"` // milliseconds
+	Attention   bool   `json:"attention"`
+}
+
+type TextRequest struct {
+	Text        string  `json:"text"`
+	Color       string  `json:"color,omitempty"`
+	Font        string  `json:"font,omitempty"`
+	Speed       float64 `json:"speed,omitempty"` // pixels a second
+	Repeat      int     `json:"repeat,omitempty"`
+	Align       string  `json:"align,omitempty"`
+	DisplayTime int     `json:"displayTime"` // milliseconds
+}
+
+type DrawingRequest struct {
+	DoubleBuffered bool `json:"doubleBuffered"`
+}
+
+type FrameRequest struct {
+	Frame string `json:"frame"` // base64 of 16x16 RGB pixels, a row at a time
+}
+
+type RectRequest struct {
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Color  string `json:"color"`
+}
+
+type LineRequest struct {
+	X1    int    `json:"x1"`
+	Y1    int    `json:"y1"`
+	X2    int    `json:"x2"`
+	Y2    int    `json:"y2"`
+	Color string `json:"color"`
+}
+
+type DrawImageRequest struct {
+	Image string `json:"image"`
+	X     int    `json:"x"`
+	Y     int    `json:"y"`
+}
+
+type ThermalState struct {
+	Throttling bool    `json:"throttling"`
+	Brightness float64 `json:"brightness"`
+	Celsius    float64 `json:"celsius"`
+}
+
+type BrightnessRequest struct {
+	Brightness      *float64 `json:"brightness,omitempty"`
+	NightEnabled    *bool    `json:"nightEnabled,omitempty"`
+	NightBrightness *float64 `json:"nightBrightness,omitempty"`
+}
+
+type BrightnessState struct {
+	Brightness      float64 `json:"brightness"`
+	NightEnabled    bool    `json:"nightEnabled"`
+	NightBrightness float64 `json:"nightBrightness"`
+	Night           bool    `json:"night"`
+	Effective       float64 `json:"effective"`
+}
+
+type RenderStats struct {
+	TargetFPS       float64 `json:"targetFps"`
+	FrameRate       float64 `json:"frameRate"`
+	FPS             float64 `json:"fps"`
+	RenderTime      float64 `json:"renderTimeMs"`
+	SerialTime      float64 `json:"serialTimeMs"`
+	FramesRendered  int     `json:"framesRendered"`
+	FramesWritten   int     `json:"framesWritten"`
+	FramesUnchanged int     `json:"framesUnchanged"`
+	FramesClean     int     `json:"framesClean"`
+	FramesFailed    int     `json:"framesFailed"`
+}
+
+type LayoutStats struct {
+	Awake             bool `json:"awake"`
+	CurrentPane       int  `json:"currentPane"`
+	Panes             int  `json:"panes"`
+	GesturesPerSecond int  `json:"gesturesPerSecond"`
+	RenderErrors      int  `json:"renderErrors"`
+	Notifications     int  `json:"notifications"`
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+
+	"github.com/lucasb-eyer/go-colorful"
+	ledmodel "github.com/ninjasphere/sphere-go-led-controller/model"
+	"github.com/ninjasphere/sphere-go-led-controller/ui"
+)
+
+// Notify shows a notification (an icon, some text or a colour) over the control layout,
+// waking the display. It's shown for its display time (or until it's tapped), then the
+// pane underneath is shown again. Notifications wait for any of the same or higher
+// priority to finish first.
+func (c *LedController) Notify(req *ledmodel.NotificationRequest) error {
+	layout, enabled := c.control()
+	if !enabled || layout == nil {
+		return fmt.Errorf("Notifications can only be shown on the control layout")
+	}
+
+	if req.Icon == "" && req.Text == "" && req.Color == "" {
+		return fmt.Errorf("A notification needs an icon, text or a color")
+	}
+
+	if req.DisplayTime < 0 {
+		return fmt.Errorf("Bad display time: %d", req.DisplayTime)
+	}
+
+	var col color.Color = color.White
+	if req.Color != "" {
+		var err error
+		if col, err = colorful.Hex(req.Color); err != nil {
+			return fmt.Errorf("Bad notification color %s: %s", req.Color, err)
+		}
+	}
+
+	pane, err := ui.NewNotificationPane(req.Icon, req.Text, col)
+	if err != nil {
+		return fmt.Errorf("Failed to create notification: %s", err)
+	}
+
+	layout.Notify(&ui.Notification{
+		Pane:        pane,
+		Priority:    req.Priority,
+		DisplayTime: time.Duration(req.DisplayTime) * time.Millisecond,
+		Attention:   req.Attention,
+	})
+
+	return nil
+}
+
+// DismissNotification stops showing the current notification, as a tap would.
+func (c *LedController) DismissNotification() error {
+	layout, _ := c.control()
+	if layout == nil {
+		return fmt.Errorf("The control layout hasn't been started yet")
+	}
+
+	layout.DismissNotification()
+	return nil
+}
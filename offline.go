@@ -0,0 +1,28 @@
+package main
+
+import (
+	"github.com/ninjasphere/go-ninja/config"
+	"github.com/ninjasphere/sphere-go-led-controller/fakes"
+	"github.com/ninjasphere/sphere-go-led-controller/ui"
+)
+
+// When set, the controller runs without the mqtt bus, against a home loaded from this
+// fixture file.
+var offlineFixture = config.String("", "led.offline.fixture")
+
+// startOffline gives the panes the fixture's home, and starts playing its events.
+func startOffline() error {
+	fixture, err := fakes.LoadFixture(offlineFixture)
+	if err != nil {
+		return err
+	}
+
+	home := fixture.Home()
+	ui.SetHome(home)
+
+	log.Infof("Running offline, with %d things from %s", len(fixture.Things), offlineFixture)
+
+	go fixture.Play(home)
+
+	return nil
+}
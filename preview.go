@@ -0,0 +1,40 @@
+package main
+
+import (
+	"image"
+
+	"github.com/ninjasphere/gestic-tools/go-gestic-sdk"
+	"github.com/ninjasphere/go-ninja/config"
+	"github.com/ninjasphere/sphere-go-led-controller/preview"
+)
+
+var enablePreview = config.Bool(false, "led.preview.enabled")
+var previewAddress = config.String("localhost:3117", "led.preview.address")
+
+// startPreview serves a live preview of each rendered frame, which can also send
+// gestures to the control layout.
+func (c *LedController) startPreview() {
+	c.preview = preview.NewServer(c.previewGesture)
+
+	go func() {
+		if err := c.preview.ListenAndServe(previewAddress); err != nil {
+			log.Errorf("Preview server failed: %s", err)
+		}
+	}()
+}
+
+func (c *LedController) previewGesture(gesture *gestic.GestureMessage) {
+	layout, enabled := c.control()
+	if !enabled {
+		log.Infof("Ignoring gesture from preview, as the control layout isn't showing")
+		return
+	}
+	layout.OnGesture(gesture)
+}
+
+// publish sends a rendered frame to the preview, if it's running.
+func (c *LedController) publish(frame *image.RGBA) {
+	if c.preview != nil {
+		c.preview.Publish(frame)
+	}
+}
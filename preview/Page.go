@@ -0,0 +1,100 @@
+package preview
+
+// The leds are drawn as soft circles, roughly how they look through the diffuser.
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Sphere LED preview</title>
+<style>
+  body { background: #111; color: #ccc; font-family: sans-serif; text-align: center; }
+  canvas { background: #000; border-radius: 8px; margin: 20px; }
+  button { background: #333; color: #eee; border: 1px solid #555; border-radius: 4px; padding: 8px 14px; margin: 2px; font-size: 14px; }
+  button:active { background: #555; }
+  #status { font-size: 12px; color: #888; }
+</style>
+</head>
+<body>
+<canvas id="matrix" width="384" height="384"></canvas>
+<div>
+  <button data-gesture="flickWestToEast" title="Left arrow">&#9664; Flick</button>
+  <button data-gesture="tap" title="Space">Tap</button>
+  <button data-gesture="doubleTap" title="d">Double tap</button>
+  <button data-gesture="flickEastToWest" title="Right arrow">Flick &#9654;</button>
+</div>
+<div>
+  <button data-gesture="airWheel" data-delta="-20" title="Down arrow">&#8634; Airwheel</button>
+  <button data-gesture="airWheel" data-delta="20" title="Up arrow">Airwheel &#8635;</button>
+</div>
+<p id="status">Connecting...</p>
+<script>
+(function() {
+  var size = 16, scale = 24;
+  var canvas = document.getElementById("matrix");
+  var ctx = canvas.getContext("2d");
+  var status = document.getElementById("status");
+  var socket;
+
+  function draw(pixels) {
+    ctx.fillStyle = "#000";
+    ctx.fillRect(0, 0, canvas.width, canvas.height);
+    for (var y = 0; y < size; y++) {
+      for (var x = 0; x < size; x++) {
+        var i = (y * size + x) * 3;
+        var cx = (x + 0.5) * scale, cy = (y + 0.5) * scale;
+        var rgb = pixels[i] + "," + pixels[i + 1] + "," + pixels[i + 2];
+        var glow = ctx.createRadialGradient(cx, cy, 0, cx, cy, scale * 0.6);
+        glow.addColorStop(0, "rgba(" + rgb + ",1)");
+        glow.addColorStop(0.6, "rgba(" + rgb + ",0.8)");
+        glow.addColorStop(1, "rgba(" + rgb + ",0)");
+        ctx.fillStyle = glow;
+        ctx.beginPath();
+        ctx.arc(cx, cy, scale * 0.6, 0, 2 * Math.PI);
+        ctx.fill();
+      }
+    }
+  }
+
+  function connect() {
+    socket = new WebSocket((location.protocol == "https:" ? "wss://" : "ws://") + location.host + "/ws");
+    socket.binaryType = "arraybuffer";
+    socket.onopen = function() { status.textContent = "Connected"; };
+    socket.onmessage = function(e) { draw(new Uint8Array(e.data)); };
+    socket.onclose = function() {
+      status.textContent = "Disconnected. Reconnecting...";
+      setTimeout(connect, 1000);
+    };
+  }
+
+  function send(gesture, delta) {
+    if (socket && socket.readyState == WebSocket.OPEN) {
+      socket.send(JSON.stringify({gesture: gesture, delta: delta || 0}));
+    }
+  }
+
+  var buttons = document.querySelectorAll("button");
+  for (var i = 0; i < buttons.length; i++) {
+    buttons[i].onclick = function() {
+      send(this.getAttribute("data-gesture"), parseInt(this.getAttribute("data-delta") || "0", 10));
+    };
+  }
+
+  var keys = {
+    37: ["flickWestToEast"], 39: ["flickEastToWest"], 32: ["tap"], 68: ["doubleTap"],
+    38: ["airWheel", 20], 40: ["airWheel", -20]
+  };
+  document.onkeydown = function(e) {
+    var key = keys[e.keyCode];
+    if (key) {
+      send(key[0], key[1]);
+      e.preventDefault();
+    }
+  };
+
+  draw(new Uint8Array(size * size * 3));
+  connect();
+})();
+</script>
+</body>
+</html>
+`
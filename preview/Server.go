@@ -0,0 +1,172 @@
+// Package preview serves a web page showing what's on the LED matrix, live, and lets
+// gestures be sent back to the panes from it.
+package preview
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"net/http"
+	"sync"
+
+	"github.com/ninjasphere/gestic-tools/go-gestic-sdk"
+	"github.com/ninjasphere/go-ninja/logger"
+	"github.com/ninjasphere/sphere-go-led-controller/gestures"
+)
+
+var log = logger.GetLogger("preview")
+
+// Server streams each published frame to the connected pages as a binary websocket
+// message of 16x16x3 bytes of RGB, and passes the gestures they send to OnGesture.
+type Server struct {
+	OnGesture func(*gestic.GestureMessage)
+
+	sync.Mutex
+	clients   map[*client]bool
+	lastFrame []byte
+	airWheel  *gestures.AirWheel
+}
+
+type client struct {
+	socket *websocket
+	frames chan []byte
+}
+
+func NewServer(onGesture func(*gestic.GestureMessage)) *Server {
+	return &Server{
+		OnGesture: onGesture,
+		clients:   make(map[*client]bool),
+		airWheel:  gestures.NewAirWheel(),
+	}
+}
+
+// ListenAndServe serves the preview page on address, returning once it fails.
+func (s *Server) ListenAndServe(address string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.servePage)
+	mux.HandleFunc("/ws", s.serveSocket)
+
+	log.Infof("Serving the live preview on http://%s/", address)
+	return http.ListenAndServe(address, mux)
+}
+
+// Publish sends a frame to every connected page. Pages that can't keep up skip frames
+// rather than holding up the display.
+func (s *Server) Publish(frame *image.RGBA) {
+	pixels := rgb(frame)
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.lastFrame = pixels
+
+	for c := range s.clients {
+		select {
+		case <-c.frames:
+			// Dropped the frame they hadn't sent yet
+		default:
+		}
+		c.frames <- pixels
+	}
+}
+
+// rgb answers the frame's pixels without the alpha channel.
+func rgb(frame *image.RGBA) []byte {
+	bounds := frame.Bounds()
+	pixels := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := frame.PixOffset(x, y)
+			pixels = append(pixels, frame.Pix[i:i+3]...)
+		}
+	}
+
+	return pixels
+}
+
+func (s *Server) servePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, page)
+}
+
+func (s *Server) serveSocket(w http.ResponseWriter, r *http.Request) {
+	socket, err := upgrade(w, r)
+	if err != nil {
+		log.Warningf("Failed to start preview websocket: %s", err)
+		return
+	}
+
+	c := &client{
+		socket: socket,
+		frames: make(chan []byte, 1),
+	}
+
+	s.Lock()
+	s.clients[c] = true
+	if s.lastFrame != nil {
+		c.frames <- s.lastFrame
+	}
+	s.Unlock()
+
+	log.Infof("Preview connected from %s", r.RemoteAddr)
+
+	done := make(chan bool)
+
+	go func() {
+		for {
+			select {
+			case frame := <-c.frames:
+				if err := socket.write(opBinary, frame); err != nil {
+					socket.Close()
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		opcode, message, err := socket.read()
+		if err != nil {
+			break
+		}
+		if opcode != opText {
+			continue
+		}
+		if err := s.gesture(message); err != nil {
+			log.Warningf("Bad gesture from preview: %s", err)
+		}
+	}
+
+	s.Lock()
+	delete(s.clients, c)
+	s.Unlock()
+
+	close(done)
+	socket.Close()
+
+	log.Infof("Preview disconnected from %s", r.RemoteAddr)
+}
+
+func (s *Server) gesture(message []byte) error {
+	var action gestures.Action
+	if err := json.Unmarshal(message, &action); err != nil {
+		return err
+	}
+
+	if err := action.Validate(); err != nil {
+		return err
+	}
+
+	if s.OnGesture != nil {
+		go action.Perform(s.airWheel, s.OnGesture)
+	}
+
+	return nil
+}
@@ -0,0 +1,228 @@
+package preview
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Just enough of RFC 6455 to send frames to a browser and read back the gestures it sends.
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// The largest message we'll read from a browser. Gestures are tiny.
+const maxMessageSize = 4096
+
+var errMessageTooBig = errors.New("websocket message too big")
+
+type websocket struct {
+	conn      net.Conn
+	reader    *bufio.Reader
+	writeLock sync.Mutex
+}
+
+// upgrade answers the handshake for a websocket request, and takes over its connection.
+func upgrade(w http.ResponseWriter, r *http.Request) (*websocket, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+
+	if r.Method != "GET" || !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "Expected a websocket", http.StatusBadRequest)
+		return nil, fmt.Errorf("Not a websocket request")
+	}
+
+	if !sameOrigin(r) {
+		http.Error(w, "Cross-origin websockets aren't allowed", http.StatusForbidden)
+		return nil, fmt.Errorf("Websocket from another origin: %s", r.Header.Get("Origin"))
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "Can't upgrade this connection", http.StatusInternalServerError)
+		return nil, fmt.Errorf("Connection can't be hijacked")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha1.Sum([]byte(key + websocketGUID))
+
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(hash[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &websocket{
+		conn:   conn,
+		reader: rw.Reader,
+	}, nil
+}
+
+// sameOrigin answers whether a request came from a page served by the preview, so other
+// sites can't send gestures to the sphere from a browser. Requests without an Origin
+// aren't from browsers, so are allowed.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func headerContains(header http.Header, name, value string) bool {
+	for _, v := range header[name] {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// write sends a single unfragmented frame. Frames from the server aren't masked.
+func (ws *websocket) write(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+
+	switch {
+	case len(payload) < 126:
+		header = append(header, byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(len(payload)))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(len(payload)))
+	}
+
+	ws.writeLock.Lock()
+	defer ws.writeLock.Unlock()
+
+	if _, err := ws.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// read answers the next text or binary message, answering pings along the way. It
+// answers io.EOF once the browser closes the connection.
+func (ws *websocket) read() (byte, []byte, error) {
+	var opcode byte
+	var message []byte
+
+	for {
+		fin, op, payload, err := ws.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch op {
+		case opClose:
+			ws.write(opClose, nil)
+			return 0, nil, io.EOF
+		case opPing:
+			if err := ws.write(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opContinuation:
+			if message == nil {
+				return 0, nil, fmt.Errorf("Unexpected continuation frame")
+			}
+		default:
+			opcode = op
+			message = []byte{}
+		}
+
+		message = append(message, payload...)
+		if len(message) > maxMessageSize {
+			return 0, nil, errMessageTooBig
+		}
+
+		if fin {
+			return opcode, message, nil
+		}
+	}
+}
+
+func (ws *websocket) readFrame() (bool, byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(ws.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if !masked {
+		return false, 0, nil, fmt.Errorf("Frames from the browser must be masked")
+	}
+
+	if length > maxMessageSize {
+		return false, 0, nil, errMessageTooBig
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.reader, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(ws.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+func (ws *websocket) Close() error {
+	return ws.conn.Close()
+}
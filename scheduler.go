@@ -0,0 +1,51 @@
+package main
+
+import (
+	"time"
+
+	"github.com/ninjasphere/go-ninja/config"
+)
+
+var targetFPS = config.Float(defaultFPS, "led.fps")
+
+const defaultFPS = 30
+
+// frameScheduler paces the render loop, so frames are only rendered as often as the
+// layout on display needs them, and never more often than the target fps.
+type frameScheduler struct {
+	last  time.Time // when the last frame was due
+	stats *renderStats
+}
+
+// wait sleeps until it's time for the next frame, which is 1/rate() seconds after the
+// last one. A rate of 0 (or over the target fps) means the target fps. The rate is asked
+// for again at the target fps, so the loop speeds up as soon as the layout needs it to,
+// e.g. when it starts panning.
+func (s *frameScheduler) wait(rate func() float64) {
+	for {
+		r := rate()
+		if r <= 0 || r > targetFPS {
+			r = targetFPS
+		}
+		if r <= 0 {
+			r = defaultFPS
+		}
+		s.stats.scheduled(r)
+
+		now := time.Now()
+		due := s.last.Add(time.Duration(float64(time.Second) / r))
+
+		if !now.Before(due) {
+			s.last = now
+			return
+		}
+
+		sleep := due.Sub(now)
+		if targetFPS > 0 {
+			if interval := time.Duration(float64(time.Second) / targetFPS); sleep > interval {
+				sleep = interval
+			}
+		}
+		time.Sleep(sleep)
+	}
+}
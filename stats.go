@@ -0,0 +1,125 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	ledmodel "github.com/ninjasphere/sphere-go-led-controller/model"
+)
+
+// renderStats counts what happened to each frame in the render loop, and measures the
+// frame rate and how long frames take to render and send over each second.
+type renderStats struct {
+	sync.Mutex
+	stats ledmodel.RenderStats
+
+	// The current second
+	windowStart time.Time
+	frames      int
+	renderTime  time.Duration
+	serialTime  time.Duration
+	serialCount int
+}
+
+func newRenderStats() *renderStats {
+	s := &renderStats{
+		windowStart: time.Now(),
+	}
+	s.stats.TargetFPS = targetFPS
+
+	go func() {
+		for _ = range time.Tick(time.Second) {
+			s.roll()
+		}
+	}()
+
+	return s
+}
+
+// roll updates the measurements with the last second's frames.
+func (s *renderStats) roll() {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(s.windowStart)
+
+	s.stats.FPS = float64(s.frames) / elapsed.Seconds()
+	s.stats.RenderTime = 0
+	if s.frames > 0 {
+		s.stats.RenderTime = milliseconds(s.renderTime / time.Duration(s.frames))
+	}
+	s.stats.SerialTime = 0
+	if s.serialCount > 0 {
+		s.stats.SerialTime = milliseconds(s.serialTime / time.Duration(s.serialCount))
+	}
+
+	s.windowStart = now
+	s.frames = 0
+	s.renderTime = 0
+	s.serialTime = 0
+	s.serialCount = 0
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// scheduled records the frame rate the render loop is running at.
+func (s *renderStats) scheduled(rate float64) {
+	s.Lock()
+	s.stats.FrameRate = rate
+	s.Unlock()
+}
+
+// rendered counts a frame that took d to render.
+func (s *renderStats) rendered(d time.Duration) {
+	s.Lock()
+	s.stats.FramesRendered++
+	s.frames++
+	s.renderTime += d
+	s.Unlock()
+}
+
+// written counts a frame that took d to send to the led matrix.
+func (s *renderStats) written(d time.Duration) {
+	s.Lock()
+	s.stats.FramesWritten++
+	s.serialTime += d
+	s.serialCount++
+	s.Unlock()
+}
+
+// failed counts a frame that couldn't be sent to the led matrix.
+func (s *renderStats) failed() {
+	s.Lock()
+	s.stats.FramesFailed++
+	s.Unlock()
+}
+
+// unchanged counts a frame that wasn't sent, as it was the same as the last one.
+func (s *renderStats) unchanged() {
+	s.Lock()
+	s.stats.FramesUnchanged++
+	s.Unlock()
+}
+
+// clean counts a frame that wasn't rendered, as nothing on the display had changed.
+func (s *renderStats) clean() {
+	s.Lock()
+	s.stats.FramesClean++
+	s.Unlock()
+}
+
+func (s *renderStats) get() *ledmodel.RenderStats {
+	s.Lock()
+	defer s.Unlock()
+
+	stats := s.stats
+	return &stats
+}
+
+// GetStats answers the frame rate, timings and counts of the frames handled by the render loop.
+func (c *LedController) GetStats() (*ledmodel.RenderStats, error) {
+	return c.stats.get(), nil
+}
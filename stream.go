@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ninjasphere/go-ninja/config"
+	"github.com/ninjasphere/sphere-go-led-controller/stream"
+)
+
+var enableStream = config.Bool(false, "led.stream.enabled")
+var streamProtocol = config.String("raw", "led.stream.protocol")
+var streamTransport = config.String("udp", "led.stream.transport")
+var streamAddress = config.String("", "led.stream.address")
+var streamMode = config.String("pane", "led.stream.mode")
+
+// How often we check if a stream has started or stopped, when it takes over the display
+const streamCheckInterval = time.Millisecond * 100
+
+// startStream listens for frames sent over the network. They are shown by a pane in
+// the control layout, or take over the display while they're arriving.
+func (c *LedController) startStream() error {
+	if streamMode != "pane" && streamMode != "takeover" {
+		return fmt.Errorf("Unknown stream mode '%s'. Try pane or takeover", streamMode)
+	}
+
+	sink, err := stream.NewSink(streamProtocol)
+	if err != nil {
+		return err
+	}
+
+	address := streamAddress
+	if address == "" {
+		address = fmt.Sprintf(":%d", stream.DefaultPort(streamProtocol))
+	}
+
+	if err := sink.Listen(streamTransport, address); err != nil {
+		return err
+	}
+
+	c.stream = sink
+
+	if streamMode == "takeover" {
+		go c.takeOverWhileStreaming(sink)
+	}
+
+	return nil
+}
+
+// takeOverWhileStreaming shows the stream in place of whatever is being shown while
+// frames are arriving, then goes back to it.
+func (c *LedController) takeOverWhileStreaming(sink *stream.Sink) {
+	var display *temporaryDisplay
+
+	for {
+		time.Sleep(streamCheckInterval)
+
+		streaming := sink.Streaming()
+
+		if display != nil && !c.isTemporaryDisplay(display) {
+			// Something has been shown in its place until further notice, so the stream
+			// takes the display back while frames are arriving
+			display = nil
+		}
+
+		if streaming && display == nil {
+			log.Infof("Frames are arriving, showing the stream")
+			display, _ = c.takeOver(0, func() error {
+				c.pairingLayout.ShowPane(sink)
+				return nil
+			})
+		}
+
+		if !streaming && display != nil {
+			log.Infof("Frames have stopped arriving")
+			c.endTemporaryDisplay(display)
+			display = nil
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package stream
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// FrameSize is the size of a frame of 16x16 RGB pixels, a row at a time.
+const FrameSize = 16 * 16 * 3
+
+// decoder reads frames sent with one of the protocols. Some protocols send a frame
+// in parts, so decode answers nil until a whole frame has been read.
+type decoder interface {
+	decode(r *bufio.Reader) ([]byte, error)
+}
+
+type protocol struct {
+	port       int // the port it's usually on
+	newDecoder func() decoder
+}
+
+var protocols = map[string]protocol{
+	"raw":  {3116, func() decoder { return &rawDecoder{frame: make([]byte, FrameSize)} }},
+	"opc":  {7890, func() decoder { return &opcDecoder{frame: make([]byte, FrameSize)} }},
+	"tpm2": {65506, func() decoder { return &tpm2Decoder{frame: make([]byte, FrameSize)} }},
+}
+
+// Protocols answers the names of the protocols frames can be sent with, sorted.
+func Protocols() []string {
+	var names []string
+	for name := range protocols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultPort answers the port a protocol is usually on, or 0 if it isn't known.
+func DefaultPort(name string) int {
+	return protocols[name].port
+}
+
+// rawDecoder reads frames of FrameSize bytes, as sent by the network led driver.
+type rawDecoder struct {
+	frame []byte
+}
+
+func (d *rawDecoder) decode(r *bufio.Reader) ([]byte, error) {
+	if _, err := io.ReadFull(r, d.frame); err != nil {
+		return nil, err
+	}
+	return d.frame, nil
+}
+
+// opcDecoder reads Open Pixel Control messages (http://openpixelcontrol.org). Only
+// "set pixel colours" messages on channel 0 (all channels) or 1 are used, and they
+// can set just the first few pixels.
+type opcDecoder struct {
+	frame []byte
+}
+
+const opcSetPixels = 0
+
+func (d *opcDecoder) decode(r *bufio.Reader) ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	channel, command := header[0], header[1]
+	data := make([]byte, int(header[2])<<8|int(header[3]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	if channel > 1 || command != opcSetPixels {
+		return nil, nil
+	}
+
+	copy(d.frame, data)
+	return d.frame, nil
+}
+
+// tpm2Decoder reads TPM2.net packets, which can each hold part of a frame, and TPM2
+// frames (as sent over serial).
+type tpm2Decoder struct {
+	frame  []byte
+	offset int // where the next packet of a frame goes
+}
+
+const (
+	tpm2NetStart = 0x9C
+	tpm2Start    = 0xC9
+	tpm2Data     = 0xDA
+	tpm2End      = 0x36
+)
+
+func (d *tpm2Decoder) decode(r *bufio.Reader) ([]byte, error) {
+	start, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	var header []byte
+	switch start {
+	case tpm2NetStart:
+		header = make([]byte, 5) // type, size, packet number, number of packets
+	case tpm2Start:
+		header = make([]byte, 3) // type, size
+	default:
+		return nil, fmt.Errorf("Bad tpm2 start byte: 0x%X", start)
+	}
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	size := int(header[1])<<8 | int(header[2])
+	data := make([]byte, size+1) // and the end byte
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	if data[len(data)-1] != tpm2End {
+		return nil, fmt.Errorf("Bad tpm2 end byte: 0x%X", data[len(data)-1])
+	}
+	data = data[:len(data)-1]
+
+	if header[0] != tpm2Data {
+		// Commands and responses aren't supported
+		return nil, nil
+	}
+
+	packet, packets := 1, 1
+	if start == tpm2NetStart {
+		packet, packets = int(header[3]), int(header[4])
+	}
+
+	if packet <= 1 {
+		d.offset = 0
+	}
+	if d.offset < len(d.frame) {
+		copy(d.frame[d.offset:], data)
+	}
+	d.offset += len(data)
+
+	if packet < packets {
+		return nil, nil
+	}
+	return d.frame, nil
+}
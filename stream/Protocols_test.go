@@ -0,0 +1,139 @@
+package stream
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+)
+
+// pixels answers n bytes of RGB, counting up from start.
+func pixels(start byte, n int) []byte {
+	rgb := make([]byte, n)
+	for i := range rgb {
+		rgb[i] = start + byte(i)
+	}
+	return rgb
+}
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func opcMessage(channel, command byte, data []byte) []byte {
+	return join([]byte{channel, command, byte(len(data) >> 8), byte(len(data))}, data)
+}
+
+func tpm2Frame(data []byte, end byte) []byte {
+	return join([]byte{tpm2Start, tpm2Data, byte(len(data) >> 8), byte(len(data))}, data, []byte{end})
+}
+
+func tpm2NetPacket(packetType byte, packet, packets byte, data []byte, end byte) []byte {
+	return join([]byte{tpm2NetStart, packetType, byte(len(data) >> 8), byte(len(data)), packet, packets}, data, []byte{end})
+}
+
+// decodeAll decodes messages until there's an error, answering the frames decoded and
+// the error (or nil, if the messages ended cleanly).
+func decodeAll(d decoder, messages []byte) ([][]byte, error) {
+	var frames [][]byte
+	r := bufio.NewReader(bytes.NewReader(messages))
+	for {
+		frame, err := d.decode(r)
+		if err == io.EOF {
+			return frames, nil
+		}
+		if err != nil {
+			return frames, err
+		}
+		if frame != nil {
+			frames = append(frames, append([]byte(nil), frame...))
+		}
+	}
+}
+
+type decodeTest struct {
+	name     string
+	messages []byte
+	frames   [][]byte // the frames decoded, the rest of each being whatever the last frame left
+	fails    bool
+}
+
+func testDecoder(t *testing.T, protocol string, tests []decodeTest) {
+	for _, test := range tests {
+		frames, err := decodeAll(protocols[protocol].newDecoder(), test.messages)
+
+		if test.fails && err == nil {
+			t.Errorf("%s %s: should have failed", protocol, test.name)
+		}
+		if !test.fails && err != nil {
+			t.Errorf("%s %s: failed: %s", protocol, test.name, err)
+		}
+
+		if len(frames) != len(test.frames) {
+			t.Errorf("%s %s: should have decoded %d frames, not %d", protocol, test.name, len(test.frames), len(frames))
+			continue
+		}
+		for i, expected := range test.frames {
+			if len(frames[i]) != FrameSize || !bytes.HasPrefix(frames[i], expected) {
+				t.Errorf("%s %s: frame %d should start with %v, not %v", protocol, test.name, i, expected, frames[i][:len(expected)])
+			}
+		}
+	}
+}
+
+func TestRaw(t *testing.T) {
+	frame := pixels(0, FrameSize)
+
+	testDecoder(t, "raw", []decodeTest{
+		{"frame", frame, [][]byte{frame}, false},
+		{"frames", join(frame, pixels(1, FrameSize)), [][]byte{frame, pixels(1, FrameSize)}, false},
+		{"short frame", frame[:100], nil, true},
+	})
+}
+
+func TestOPC(t *testing.T) {
+	frame := pixels(0, FrameSize)
+
+	testDecoder(t, "opc", []decodeTest{
+		{"all channels", opcMessage(0, opcSetPixels, frame), [][]byte{frame}, false},
+		{"channel 1", opcMessage(1, opcSetPixels, frame), [][]byte{frame}, false},
+		{"first pixels", opcMessage(0, opcSetPixels, pixels(9, 6)), [][]byte{pixels(9, 6)}, false},
+		{"extra pixels", opcMessage(0, opcSetPixels, pixels(0, FrameSize+30)), [][]byte{frame}, false},
+		{"other channel", opcMessage(2, opcSetPixels, frame), nil, false},
+		{"other command", join(opcMessage(0, 0xFF, pixels(7, 4)), opcMessage(0, opcSetPixels, frame)), [][]byte{frame}, false},
+		{"short header", []byte{0, opcSetPixels, 3}, nil, true},
+		{"short data", opcMessage(0, opcSetPixels, frame)[:100], nil, true},
+	})
+}
+
+func TestTPM2(t *testing.T) {
+	frame := pixels(0, FrameSize)
+	first, second := frame[:FrameSize/2], frame[FrameSize/2:]
+
+	testDecoder(t, "tpm2", []decodeTest{
+		{"tpm2 frame", tpm2Frame(frame, tpm2End), [][]byte{frame}, false},
+		{"tpm2 frames", join(tpm2Frame(frame, tpm2End), tpm2Frame(pixels(5, 3), tpm2End)), [][]byte{frame, pixels(5, 3)}, false},
+		{"tpm2.net frame", tpm2NetPacket(tpm2Data, 1, 1, frame, tpm2End), [][]byte{frame}, false},
+		{"tpm2.net packets", join(
+			tpm2NetPacket(tpm2Data, 1, 2, first, tpm2End),
+			tpm2NetPacket(tpm2Data, 2, 2, second, tpm2End),
+		), [][]byte{frame}, false},
+		{"tpm2.net restarted frame", join(
+			tpm2NetPacket(tpm2Data, 1, 2, pixels(100, FrameSize/2), tpm2End),
+			tpm2NetPacket(tpm2Data, 1, 2, first, tpm2End),
+			tpm2NetPacket(tpm2Data, 2, 2, second, tpm2End),
+		), [][]byte{frame}, false},
+		{"tpm2.net unnumbered packet", tpm2NetPacket(tpm2Data, 0, 0, frame, tpm2End), [][]byte{frame}, false},
+		{"tpm2.net extra pixels", tpm2NetPacket(tpm2Data, 1, 1, pixels(0, FrameSize+30), tpm2End), [][]byte{frame}, false},
+		{"command", join(tpm2NetPacket(0xC0, 1, 1, pixels(0, 2), tpm2End), tpm2Frame(frame, tpm2End)), [][]byte{frame}, false},
+		{"bad start byte", join([]byte{0xAA}, tpm2Frame(frame, tpm2End)), nil, true},
+		{"bad end byte", tpm2Frame(frame, 0x37), nil, true},
+		{"tpm2.net bad end byte", join(
+			tpm2NetPacket(tpm2Data, 1, 2, first, tpm2End),
+			tpm2NetPacket(tpm2Data, 2, 2, second, 0x00),
+		), nil, true},
+		{"short header", []byte{tpm2NetStart, tpm2Data, 0x03}, nil, true},
+		{"short data", tpm2Frame(frame, tpm2End)[:100], nil, true},
+		{"missing end byte", tpm2Frame(frame, tpm2End)[:4+FrameSize], nil, true},
+	})
+}
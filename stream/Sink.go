@@ -0,0 +1,190 @@
+// Package stream receives frames sent over the network by anything (e.g. light show
+// software), as raw RGB or with one of the common led protocols, and shows them as a
+// pane.
+package stream
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ninjasphere/gestic-tools/go-gestic-sdk"
+	"github.com/ninjasphere/go-ninja/config"
+	"github.com/ninjasphere/go-ninja/logger"
+)
+
+var log = logger.GetLogger("stream")
+
+// How long after the last frame the stream is thought to have stopped
+var streamTimeout = config.Duration(time.Second*2, "led.stream.timeout")
+
+// Sink receives frames, and is a pane showing the last one. It's only enabled while
+// frames are arriving.
+type Sink struct {
+	sync.Mutex
+	protocol string
+	frame    *image.RGBA
+	received time.Time // when the last frame arrived
+	changed  bool
+}
+
+// NewSink answers a sink for frames sent with a protocol (see Protocols).
+func NewSink(protocol string) (*Sink, error) {
+	if _, ok := protocols[protocol]; !ok {
+		return nil, fmt.Errorf("Unknown stream protocol '%s'. Try one of %s", protocol, strings.Join(Protocols(), ", "))
+	}
+
+	return &Sink{
+		protocol: protocol,
+		frame:    image.NewRGBA(image.Rect(0, 0, 16, 16)),
+	}, nil
+}
+
+// Listen starts receiving frames over transport (udp or tcp) at address.
+func (s *Sink) Listen(transport string, address string) error {
+	switch transport {
+	case "udp":
+		conn, err := net.ListenPacket("udp", address)
+		if err != nil {
+			return err
+		}
+		go s.receivePackets(conn)
+	case "tcp":
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			return err
+		}
+		go s.accept(listener)
+	default:
+		return fmt.Errorf("Unknown stream transport '%s'. Try udp or tcp", transport)
+	}
+
+	log.Infof("Listening for %s frames over %s on %s", s.protocol, transport, address)
+	return nil
+}
+
+// Each datagram holds one or more messages
+func (s *Sink) receivePackets(conn net.PacketConn) {
+	defer conn.Close()
+
+	d := protocols[s.protocol].newDecoder()
+	buf := make([]byte, 65536)
+
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Errorf("Stopped receiving frames: %s", err)
+			return
+		}
+
+		r := bufio.NewReader(bytes.NewReader(buf[:n]))
+		for {
+			if err := s.receive(d, r); err != nil {
+				if err != io.EOF {
+					log.Debugf("Dropped a bad packet from %s: %s", from, err)
+				}
+				break
+			}
+		}
+	}
+}
+
+func (s *Sink) accept(listener net.Listener) {
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Errorf("Stopped accepting frame streams: %s", err)
+			return
+		}
+		go s.receiveStream(conn)
+	}
+}
+
+// A connection is a stream of messages
+func (s *Sink) receiveStream(conn net.Conn) {
+	defer conn.Close()
+
+	log.Infof("Frame stream connected from %s", conn.RemoteAddr())
+
+	d := protocols[s.protocol].newDecoder()
+	r := bufio.NewReader(conn)
+
+	for {
+		if err := s.receive(d, r); err != nil {
+			if err != io.EOF {
+				log.Warningf("Closing frame stream from %s: %s", conn.RemoteAddr(), err)
+			}
+			log.Infof("Frame stream from %s disconnected", conn.RemoteAddr())
+			return
+		}
+	}
+}
+
+// receive decodes the next message, showing the frame if it's complete.
+func (s *Sink) receive(d decoder, r *bufio.Reader) error {
+	rgb, err := d.decode(r)
+	if err != nil || rgb == nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	for i := 0; i < FrameSize/3; i++ {
+		copy(s.frame.Pix[i*4:], rgb[i*3:i*3+3])
+		s.frame.Pix[i*4+3] = 255
+	}
+	s.received = time.Now()
+	s.changed = true
+
+	return nil
+}
+
+// Streaming answers whether frames are arriving.
+func (s *Sink) Streaming() bool {
+	s.Lock()
+	defer s.Unlock()
+	return time.Since(s.received) < streamTimeout
+}
+
+func (s *Sink) IsEnabled() bool {
+	return s.Streaming()
+}
+
+func (s *Sink) KeepAwake() bool {
+	return s.Streaming()
+}
+
+func (s *Sink) Gesture(gesture *gestic.GestureMessage) {
+}
+
+func (s *Sink) Render() (*image.RGBA, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	// Copied, as the next frame can arrive while this one is being written
+	frame := image.NewRGBA(s.frame.Bounds())
+	copy(frame.Pix, s.frame.Pix)
+	s.changed = false
+
+	return frame, nil
+}
+
+func (s *Sink) IsDirty() bool {
+	s.Lock()
+	defer s.Unlock()
+	return s.changed
+}
+
+// FrameRate is as many as possible, so frames are shown as soon as they arrive
+func (s *Sink) FrameRate() float64 {
+	return 0
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ninjasphere/go-ninja/config"
+	"github.com/ninjasphere/sphere-go-led-controller/util"
+)
+
+var temperatureInterval = config.Duration(time.Second*30, "led.temperature.interval")
+
+// startSamplingTemperature starts sampling the temperature, if the matrix can read it.
+// The services must have been exported first, so the readings can be sent as events.
+func (c *LedController) startSamplingTemperature() {
+	if firmware, ok := c.matrix.(util.FirmwareMatrix); ok {
+		go c.sampleTemperature(firmware)
+	}
+}
+
+// sampleTemperature periodically reads the matrix temperature sensor, publishing
+// each reading as a "temperature" event, and passing it on to the thermal governor.
+func (c *LedController) sampleTemperature(firmware util.FirmwareMatrix) {
+	for {
+		temperature, err := firmware.Temperature()
+		if err != nil {
+			log.Warningf("Failed to read LED matrix temperature: %s", err)
+		} else {
+			log.Debugf("LED matrix temperature: %+v", temperature)
+			c.sendEvent("temperature", temperature)
+
+			if c.governor.update(temperature.Celsius) {
+				state := c.governor.get()
+				c.sendEvent("thermal", &state)
+			}
+		}
+
+		time.Sleep(temperatureInterval)
+	}
+}
+
+// GetTemperature answers a fresh reading from the matrix's temperature sensor.
+func (c *LedController) GetTemperature() (*util.Temperature, error) {
+	firmware, ok := c.matrix.(util.FirmwareMatrix)
+	if !ok {
+		return nil, fmt.Errorf("The %s LED matrix driver can't read the temperature", c.matrix.Info().Driver)
+	}
+	return firmware.Temperature()
+}
+
+// GetMatrixInfo describes the LED matrix, including its firmware version.
+func (c *LedController) GetMatrixInfo() (*util.MatrixInfo, error) {
+	info := c.matrix.Info()
+	return &info, nil
+}
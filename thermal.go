@@ -0,0 +1,105 @@
+package main
+
+import (
+	"math"
+	"sync"
+
+	"github.com/ninjasphere/go-ninja/config"
+	ledmodel "github.com/ninjasphere/sphere-go-led-controller/model"
+)
+
+var thermalEnabled = config.Bool(true, "led.thermal.enabled")
+
+// Throttling starts at thermalStart, and reaches thermalMinBrightness at thermalMax (in °C).
+// The matrix firmware starts dimming on its own at around 52°C.
+var thermalStart = config.Float(48, "led.thermal.start")
+var thermalMax = config.Float(58, "led.thermal.max")
+var thermalMinBrightness = config.Float(0.3, "led.thermal.minBrightness")
+
+// How far the temperature must fall (in °C) before the brightness is raised again
+var thermalHysteresis = config.Float(2, "led.thermal.hysteresis")
+
+// thermalGovernor scales down the brightness of the whole display as the matrix
+// heats up, so full white panes can't cook it.
+type thermalGovernor struct {
+	sync.Mutex
+	state ledmodel.ThermalState
+}
+
+func newThermalGovernor() *thermalGovernor {
+	return &thermalGovernor{
+		state: ledmodel.ThermalState{
+			Brightness: 1,
+		},
+	}
+}
+
+// The brightness we want at a given temperature
+func thermalTarget(celsius float64) float64 {
+	if celsius <= thermalStart {
+		return 1
+	}
+	if celsius >= thermalMax {
+		return thermalMinBrightness
+	}
+	return 1 - (1-thermalMinBrightness)*(celsius-thermalStart)/(thermalMax-thermalStart)
+}
+
+// update adjusts the brightness for a new temperature reading. The brightness drops as
+// soon as it gets hotter, but only comes back up once it has cooled by the hysteresis.
+// Answers true if throttling has started or stopped.
+func (g *thermalGovernor) update(celsius float64) bool {
+	g.Lock()
+	defer g.Unlock()
+
+	g.state.Celsius = celsius
+
+	if !thermalEnabled {
+		return false
+	}
+
+	brightness := g.state.Brightness
+
+	if down := thermalTarget(celsius); down < brightness {
+		brightness = down
+	} else if up := thermalTarget(celsius + thermalHysteresis); up > brightness {
+		brightness = up
+	}
+
+	if math.Abs(brightness-g.state.Brightness) > 0.001 {
+		log.Infof("LED matrix is at %.1f°C. Changing brightness from %.2f to %.2f", celsius, g.state.Brightness, brightness)
+	}
+	g.state.Brightness = brightness
+
+	throttling := brightness < 1
+	changed := throttling != g.state.Throttling
+	g.state.Throttling = throttling
+
+	if changed {
+		if throttling {
+			log.Warningf("LED matrix is too hot (%.1f°C). Throttling brightness.", celsius)
+		} else {
+			log.Infof("LED matrix has cooled down (%.1f°C). No longer throttling brightness.", celsius)
+		}
+	}
+
+	return changed
+}
+
+func (g *thermalGovernor) brightness() float64 {
+	g.Lock()
+	defer g.Unlock()
+	return g.state.Brightness
+}
+
+func (g *thermalGovernor) get() ledmodel.ThermalState {
+	g.Lock()
+	defer g.Unlock()
+	return g.state
+}
+
+// GetThermalState answers whether the display is currently being throttled to keep it cool.
+func (c *LedController) GetThermalState() (*ledmodel.ThermalState, error) {
+	state := c.governor.get()
+	return &state, nil
+}
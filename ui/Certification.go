@@ -25,6 +25,16 @@ type CertPane struct {
 	tag       string
 }
 
+func init() {
+	RegisterPane("certification", func(ctx *PaneContext) (Pane, error) {
+		var mqtt bus.Bus
+		if ctx.Conn != nil {
+			mqtt = ctx.Conn.GetMqttClient()
+		}
+		return NewCertPane(mqtt), nil
+	})
+}
+
 func NewCertPane(conn bus.Bus) *CertPane {
 
 	log := logger.GetLogger("CertPane")
@@ -37,6 +47,11 @@ func NewCertPane(conn bus.Bus) *CertPane {
 		return pane
 	}
 
+	if conn == nil {
+		log.Warningf("There's no mqtt bus, so there are no waypoints or rssi to show")
+		return pane
+	}
+
 	_, err := conn.Subscribe("$location/waypoints", func(topic string, payload []byte) {
 		var waypoints int
 		err := json.Unmarshal(payload, &waypoints)
@@ -8,7 +8,6 @@ import (
 	"time"
 
 	"github.com/ninjasphere/gestic-tools/go-gestic-sdk"
-	"github.com/ninjasphere/go-ninja/api"
 	"github.com/ninjasphere/go-ninja/config"
 	"github.com/ninjasphere/sphere-go-led-controller/fonts/clock"
 )
@@ -16,14 +15,20 @@ import (
 var enableClockPane = config.Bool(true, "led.clock.enabled")
 var enableAlarm = config.Bool(true, "led.clock.alarmEnabled")
 var alarmFlashTimes = config.Int(2, "led.clock.alarmFlashTimes") * 2
-var alarmFlashInterval = config.MustDuration("led.clock.alarmFlashInterval")
+var alarmFlashInterval = config.Duration(time.Millisecond*500, "led.clock.alarmFlashInterval")
 
 type ClockPane struct {
 	alarm       *time.Time
 	timer       *time.Timer
 	tapThrottle *throttle
-	lights      []*ninja.ServiceClient
-	tickTock    bool
+	lights      []*Device
+	lastText    string // the text shown by the last frame rendered
+}
+
+func init() {
+	RegisterPane("clock", func(ctx *PaneContext) (Pane, error) {
+		return NewClockPane(), nil
+	})
 }
 
 func NewClockPane() *ClockPane {
@@ -40,7 +45,7 @@ func NewClockPane() *ClockPane {
 	if enableAlarm {
 		enableAlarm = false
 
-		getChannelServicesContinuous("light", "on-off", nil, func(devices []*ninja.ServiceClient, err error) {
+		getChannelServicesContinuous("light", "on-off", nil, func(devices []*Device, err error) {
 			if err != nil {
 				log.Infof("Failed to update on-off devices: %s", err)
 				enableAlarm = false
@@ -61,13 +66,6 @@ func NewClockPane() *ClockPane {
 		})
 	}
 
-	go func() {
-		tick := time.Tick(time.Second)
-		for _ = range tick {
-			pane.tickTock = !pane.tickTock
-		}
-	}()
-
 	return pane
 }
 
@@ -86,14 +84,14 @@ func (p *ClockPane) Gesture(gesture *gestic.GestureMessage) {
 
 	if gesture.Tap.Active() && p.tapThrottle.try() {
 		if p.alarm == nil {
-			x := time.Now().Add(time.Minute)
+			x := now().Add(time.Minute)
 			p.alarm = &x
 		} else {
 			x := p.alarm.Add(time.Minute)
 			p.alarm = &x
 		}
 
-		p.timer.Reset(p.alarm.Sub(time.Now()))
+		p.timer.Reset(p.alarm.Sub(now()))
 	}
 
 	if gesture.DoubleTap.Active() {
@@ -107,7 +105,7 @@ func (p *ClockPane) DoAlarm() {
 	log.Infof("Alarm Activated! Flashing %d lights %d times", len(p.lights), alarmFlashTimes)
 
 	for _, device := range p.lights {
-		go func(d *ninja.ServiceClient) {
+		go func(d *Device) {
 			for i := 0; i < alarmFlashTimes; i++ {
 				d.Call("toggle", nil, nil, 0)
 				time.Sleep(time.Second * 2)
@@ -117,16 +115,15 @@ func (p *ClockPane) DoAlarm() {
 
 }
 
-func (p *ClockPane) Render() (*image.RGBA, error) {
-	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
-
+// text answers what the clock is showing
+func (p *ClockPane) text() string {
 	var text string
 	if p.alarm != nil {
-		duration := p.alarm.Sub(time.Now())
+		duration := p.alarm.Sub(now())
 		text = fmt.Sprintf("%d:%02d", int(duration.Minutes()), int(duration.Seconds())-(int(duration.Minutes())*60))
 	} else {
 
-		t := time.Now()
+		t := now()
 
 		if timezone != nil {
 			t = t.In(timezone)
@@ -136,11 +133,20 @@ func (p *ClockPane) Render() (*image.RGBA, error) {
 		if text[0] == '0' { // 0 is too wide
 			text = text[1:]
 		}
-		if p.tickTock {
+		if t.Second()%2 == 1 { // the colon ticks every second
 			text = strings.Replace(text, ":", ";", 1)
 		}
 	}
 
+	return text
+}
+
+func (p *ClockPane) Render() (*image.RGBA, error) {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+
+	text := p.text()
+	p.lastText = text
+
 	width := clock.Font.DrawString(img, 0, 0, text, color.Black)
 	start := 16 - width
 
@@ -151,6 +157,11 @@ func (p *ClockPane) Render() (*image.RGBA, error) {
 	return img, nil
 }
 
+// FrameRate is twice a second, to catch the colon ticking every second
+func (p *ClockPane) FrameRate() float64 {
+	return 2
+}
+
 func (p *ClockPane) IsDirty() bool {
-	return true
+	return p.text() != p.lastText
 }
@@ -0,0 +1,205 @@
+package ui
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+
+	"github.com/ninjasphere/gestic-tools/go-gestic-sdk"
+)
+
+// FrameSize is the size of a frame of 16x16 RGB pixels, as sent by DrawFrame.
+const FrameSize = width * height * 3
+
+// Drawing is a pane showing what it has been told to draw. A double buffered drawing
+// only shows what has been drawn when it's committed, so a frame can be drawn in
+// several steps without showing the steps.
+type Drawing struct {
+	sync.Mutex
+	front   *image.RGBA // what is shown
+	back    *image.RGBA // what is drawn on, which is front unless double buffered
+	changed bool
+}
+
+// NewDrawing answers a blank drawing.
+func NewDrawing(doubleBuffered bool) *Drawing {
+	d := &Drawing{
+		front:   image.NewRGBA(image.Rect(0, 0, width, height)),
+		changed: true,
+	}
+	d.back = d.front
+	if doubleBuffered {
+		d.back = image.NewRGBA(image.Rect(0, 0, width, height))
+	}
+	return d
+}
+
+// drew notes that the drawing has changed, which is shown straight away unless the
+// drawing is double buffered. It must be called with the lock held.
+func (d *Drawing) drew() {
+	if d.back == d.front {
+		d.changed = true
+	}
+}
+
+// SetPixels sets pixels from x,y,r,g,b tuples. Nothing is drawn if any are bad.
+func (d *Drawing) SetPixels(updates [][]uint8) error {
+	for i, update := range updates {
+		if len(update) != 5 {
+			return fmt.Errorf("Pixel %d should be x,y,r,g,b, not %v", i, update)
+		}
+		if update[0] >= width || update[1] >= height {
+			return fmt.Errorf("Pixel %d (%d,%d) is off the display", i, update[0], update[1])
+		}
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	for _, update := range updates {
+		d.back.SetRGBA(int(update[0]), int(update[1]), color.RGBA{update[2], update[3], update[4], 255})
+	}
+	d.drew()
+
+	return nil
+}
+
+// SetFrame replaces the whole drawing with FrameSize bytes of RGB, a row at a time.
+func (d *Drawing) SetFrame(rgb []byte) error {
+	if len(rgb) != FrameSize {
+		return fmt.Errorf("A frame should be %d bytes of RGB, not %d bytes", FrameSize, len(rgb))
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	for i := 0; i < width*height; i++ {
+		copy(d.back.Pix[i*4:], rgb[i*3:i*3+3])
+		d.back.Pix[i*4+3] = 255
+	}
+	d.drew()
+
+	return nil
+}
+
+// FillRect fills the part of a rectangle that is on the display.
+func (d *Drawing) FillRect(r image.Rectangle, c color.Color) error {
+	if r.Dx() <= 0 || r.Dy() <= 0 {
+		return fmt.Errorf("Can't fill %v, as it's empty", r)
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	draw.Draw(d.back, r.Intersect(d.back.Bounds()), &image.Uniform{c}, image.ZP, draw.Src)
+	d.drew()
+
+	return nil
+}
+
+// Line draws a line between two points on the display (inclusive).
+func (d *Drawing) Line(from, to image.Point, c color.Color) error {
+	for _, p := range []image.Point{from, to} {
+		if !p.In(d.back.Bounds()) {
+			return fmt.Errorf("%v is off the display", p)
+		}
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	// Bresenham's
+	dx, sx := to.X-from.X, 1
+	if dx < 0 {
+		dx, sx = -dx, -1
+	}
+	dy, sy := to.Y-from.Y, 1
+	if dy < 0 {
+		dy, sy = -dy, -1
+	}
+
+	err := dx - dy
+	p := from
+	for {
+		d.back.Set(p.X, p.Y, c)
+
+		if p == to {
+			break
+		}
+
+		e2 := 2 * err
+		if e2 > -dy {
+			err -= dy
+			p.X += sx
+		}
+		if e2 < dx {
+			err += dx
+			p.Y += sy
+		}
+	}
+	d.drew()
+
+	return nil
+}
+
+// DrawImage draws an image over the drawing with its top left at at.
+func (d *Drawing) DrawImage(img image.Image, at image.Point) {
+	d.Lock()
+	defer d.Unlock()
+
+	bounds := img.Bounds()
+	draw.Draw(d.back, bounds.Sub(bounds.Min).Add(at), img, bounds.Min, draw.Over)
+	d.drew()
+}
+
+// Clear blanks the drawing.
+func (d *Drawing) Clear() {
+	d.Lock()
+	defer d.Unlock()
+
+	draw.Draw(d.back, d.back.Bounds(), image.Transparent, image.ZP, draw.Src)
+	d.drew()
+}
+
+// Commit shows what has been drawn since the last commit. It does nothing if the
+// drawing isn't double buffered, as everything is shown as it's drawn.
+func (d *Drawing) Commit() {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.back != d.front {
+		copy(d.front.Pix, d.back.Pix)
+		d.changed = true
+	}
+}
+
+func (d *Drawing) IsEnabled() bool {
+	return true
+}
+
+func (d *Drawing) KeepAwake() bool {
+	return false
+}
+
+func (d *Drawing) Gesture(gesture *gestic.GestureMessage) {
+}
+
+func (d *Drawing) Render() (*image.RGBA, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	// Copied, as it can be drawn on while the frame is being written
+	frame := image.NewRGBA(d.front.Bounds())
+	copy(frame.Pix, d.front.Pix)
+	d.changed = false
+
+	return frame, nil
+}
+
+func (d *Drawing) IsDirty() bool {
+	d.Lock()
+	defer d.Unlock()
+	return d.changed
+}
@@ -15,6 +15,12 @@ type GameOfLifePane struct {
 	life *Life
 }
 
+func init() {
+	RegisterPane("gameOfLife", func(ctx *PaneContext) (Pane, error) {
+		return NewGameOfLifePane(), nil
+	})
+}
+
 func NewGameOfLifePane() *GameOfLifePane {
 	pane := &GameOfLifePane{}
 	pane.reset()
@@ -63,6 +69,11 @@ func (p *GameOfLifePane) Render() (*image.RGBA, error) {
 	return img, nil
 }
 
+// FrameRate is the number of generations a second, as each frame is the next generation
+func (p *GameOfLifePane) FrameRate() float64 {
+	return 10
+}
+
 func (p *GameOfLifePane) IsDirty() bool {
 	return true
 }
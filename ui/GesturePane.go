@@ -17,6 +17,12 @@ type GesturePane struct {
 	last *gestic.GestureMessage
 }
 
+func init() {
+	RegisterPane("gesture", func(ctx *PaneContext) (Pane, error) {
+		return NewGesturePane(), nil
+	})
+}
+
 func NewGesturePane() *GesturePane {
 	return &GesturePane{}
 }
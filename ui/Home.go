@@ -0,0 +1,118 @@
+package ui
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ninjasphere/go-ninja/api"
+	"github.com/ninjasphere/go-ninja/bus"
+	"github.com/ninjasphere/go-ninja/config"
+	"github.com/ninjasphere/go-ninja/model"
+)
+
+// Home is where the panes find the things in the home, and talk to their devices. It is
+// normally the ThingModel and the mqtt bus, but can be replaced with SetHome.
+type Home interface {
+	// FetchAll answers all of the things in the home.
+	FetchAll() ([]model.Thing, error)
+	// Device answers a client for one of a thing's channels.
+	Device(channel *model.Channel) *Device
+	// OnChange calls changed whenever things are created, updated or deleted.
+	OnChange(changed func())
+	// Paired answers whether this Sphere has been paired, and so has a site.
+	Paired() bool
+	// FetchSite answers this Sphere's site.
+	FetchSite() (*model.Site, error)
+	// Service answers a client for any other service, e.g. a topic pattern like
+	// $device/:deviceId/component/:componentId.
+	Service(topic string) DeviceClient
+}
+
+// DeviceClient calls methods on, and listens to the events of, a device's channel.
+// It is implemented by *ninja.ServiceClient.
+type DeviceClient interface {
+	Call(method string, args interface{}, reply interface{}, timeout time.Duration) error
+	OnEvent(event string, callback interface{}) (*bus.Subscription, error)
+}
+
+// Device is a channel of a thing that a pane is controlling.
+type Device struct {
+	DeviceClient
+	Topic            string
+	SupportedMethods []string
+}
+
+var home Home
+
+// SetHome replaces the ThingModel and mqtt bus used by the panes. It must be called
+// before the panes (or the pane layout) are made.
+func SetHome(h Home) {
+	home = h
+}
+
+// getHome answers the home set with SetHome, or the one seen over conn if there isn't
+// one yet.
+func getHome(conn *ninja.Connection) Home {
+	if home == nil {
+		home = NewConnectionHome(conn)
+	}
+	return home
+}
+
+// connectionHome is the ThingModel, and the devices on the mqtt bus.
+type connectionHome struct {
+	conn       *ninja.Connection
+	thingModel *ninja.ServiceClient
+	siteModel  *ninja.ServiceClient
+}
+
+// NewConnectionHome answers the home seen over a connection to the mqtt bus.
+func NewConnectionHome(conn *ninja.Connection) Home {
+	return &connectionHome{
+		conn:       conn,
+		thingModel: conn.GetServiceClient("$home/services/ThingModel"),
+		siteModel:  conn.GetServiceClient("$home/services/SiteModel"),
+	}
+}
+
+func (h *connectionHome) FetchAll() ([]model.Thing, error) {
+	var things []model.Thing
+	err := h.thingModel.Call("fetchAll", []interface{}{}, &things, time.Second*20)
+	//err = client.Call("fetch", "c7ac05e0-9999-4d93-bfe3-a0b4bb5e7e78", &thing)
+	return things, err
+}
+
+func (h *connectionHome) Device(channel *model.Channel) *Device {
+	client := h.conn.GetServiceClientFromAnnouncement(channel.ServiceAnnouncement)
+	return &Device{
+		DeviceClient:     client,
+		Topic:            client.Topic,
+		SupportedMethods: client.SupportedMethods,
+	}
+}
+
+func (h *connectionHome) OnChange(changed func()) {
+	onChange := func(params *json.RawMessage, topicKeys map[string]string) bool {
+		changed()
+		return true
+	}
+
+	h.thingModel.OnEvent("created", onChange)
+	h.thingModel.OnEvent("updated", onChange)
+	h.thingModel.OnEvent("deleted", onChange)
+}
+
+func (h *connectionHome) Paired() bool {
+	config.MustRefresh()
+	return config.HasString("siteId")
+}
+
+func (h *connectionHome) FetchSite() (*model.Site, error) {
+	site := &model.Site{}
+	err := h.siteModel.Call("fetch", config.MustString("siteId"), site, time.Second*5)
+	return site, err
+}
+
+func (h *connectionHome) Service(topic string) DeviceClient {
+	return h.conn.GetServiceClient(topic)
+}
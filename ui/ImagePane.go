@@ -17,6 +17,18 @@ func NewImagePane(image string) *ImagePane {
 	}
 }
 
+// OpenImagePane answers a pane showing an image, or an error if the image can't be loaded.
+func OpenImagePane(image string) (*ImagePane, error) {
+	img, err := util.OpenImage(image)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ImagePane{
+		image: img,
+	}, nil
+}
+
 func (p *ImagePane) IsEnabled() bool {
 	return true
 }
@@ -33,5 +45,9 @@ func (p *ImagePane) Render() (*image.RGBA, error) {
 }
 
 func (p *ImagePane) IsDirty() bool {
-	return true
+	return util.IsImageDirty(p.image)
+}
+
+func (p *ImagePane) FrameRate() float64 {
+	return util.ImageFrameRate(p.image)
 }
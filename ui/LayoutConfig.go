@@ -0,0 +1,96 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/ninjasphere/go-ninja/api"
+)
+
+// LayoutConfig describes the panes shown in the control layout, in order.
+type LayoutConfig struct {
+	Panes []PaneConfig `json:"panes"`
+}
+
+// PaneConfig describes a single pane. Only type is required, the rest depend on the
+// type of pane. Images are relative to the images directory.
+type PaneConfig struct {
+	Type      string          `json:"type"`
+	ThingType string          `json:"thingType,omitempty"`
+	OffImage  string          `json:"offImage,omitempty"`
+	OnImage   string          `json:"onImage,omitempty"`
+	Options   json.RawMessage `json:"options,omitempty"`
+}
+
+// DecodeOptions unmarshals the pane's options into v, leaving it alone if there are none.
+func (c *PaneConfig) DecodeOptions(v interface{}) error {
+	if len(c.Options) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(c.Options, v); err != nil {
+		return fmt.Errorf("Bad options for %s pane: %s", c.Type, err)
+	}
+	return nil
+}
+
+// DefaultLayoutConfig is used when there is no layout file.
+var DefaultLayoutConfig = LayoutConfig{
+	Panes: []PaneConfig{
+		{Type: "clock"},
+		{Type: "weather"},
+		{Type: "gesture"},
+		{Type: "gameOfLife"},
+		{Type: "media"},
+		{Type: "certification"},
+		{Type: "onoff", ThingType: "lamp", OffImage: "lamp2-off.gif", OnImage: "lamp2-on.gif"},
+		{Type: "onoff", ThingType: "heater", OffImage: "heater-off.png", OnImage: "heater-on.gif"},
+		{Type: "light", OffImage: "light-off.png", OnImage: "light-on.png"},
+		{Type: "light", OffImage: "light-off.png", OnImage: "light-on.png", Options: json.RawMessage(`{"colorMode":true}`)},
+		{Type: "onoff", ThingType: "fan", OffImage: "fan-off.png", OnImage: "fan-on.gif"},
+		{Type: "onoff", ThingType: "aircon", OffImage: "fan-off.png", OnImage: "fan-on.gif"},
+		{Type: "system"},
+	},
+}
+
+// LoadLayoutConfig reads a layout file, falling back to the default layout if it doesn't exist.
+func LoadLayoutConfig(path string) (*LayoutConfig, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		log.Infof("No pane layout file at %s. Using the default layout.", path)
+		layout := DefaultLayoutConfig
+		return &layout, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read pane layout %s : %s", path, err)
+	}
+
+	var layout LayoutConfig
+	if err := json.Unmarshal(data, &layout); err != nil {
+		return nil, fmt.Errorf("Failed to parse pane layout %s : %s", path, err)
+	}
+
+	if len(layout.Panes) == 0 {
+		return nil, fmt.Errorf("Pane layout %s has no panes", path)
+	}
+
+	return &layout, nil
+}
+
+// BuildPanes creates the panes described by a layout.
+func BuildPanes(layout *LayoutConfig, conn *ninja.Connection) ([]Pane, error) {
+	var panes []Pane
+
+	for i := range layout.Panes {
+		pane, err := NewPaneFromConfig(&layout.Panes[i], conn)
+		if err != nil {
+			return nil, fmt.Errorf("Pane %d: %s", i, err)
+		}
+		panes = append(panes, pane)
+	}
+
+	return panes, nil
+}
@@ -18,21 +18,21 @@ import (
 	"github.com/ninjasphere/sphere-go-led-controller/util"
 )
 
-var lightTapInterval = config.MustDuration("led.light.tapInterval")
-var colorInterval = config.MustDuration("led.light.colorInterval")
+var lightTapInterval = config.Duration(time.Millisecond*500, "led.light.tapInterval")
+var colorInterval = config.Duration(time.Millisecond*50, "led.light.colorInterval")
 
-var colorAdjustSpeed = config.MustFloat("led.light.colorSpeed")
-var brightnessAdjustSpeed = config.MustFloat("led.light.brightnessSpeed")
+var colorAdjustSpeed = config.Float(1.0, "led.light.colorSpeed")
+var brightnessAdjustSpeed = config.Float(1.0, "led.light.brightnessSpeed")
 
-var brightnessMinimum = uint8(config.MustInt("led.light.brightnessMinimum"))
+var brightnessMinimum = uint8(config.Int(20, "led.light.brightnessMinimum"))
 
 type LightPane struct {
 	log      *logger.Logger
 	conn     *ninja.Connection
 	onEnable chan bool
 
-	onOffDevices    *[]*ninja.ServiceClient
-	airwheelDevices *[]*ninja.ServiceClient
+	onOffDevices    *[]*Device
+	airwheelDevices *[]*Device
 
 	onOffState bool
 	lastTap    time.Time
@@ -52,6 +52,31 @@ type LightPane struct {
 	gestureSync *sync.Mutex
 }
 
+type lightPaneOptions struct {
+	ColorMode bool `json:"colorMode"`
+}
+
+func init() {
+	RegisterPane("light", func(ctx *PaneContext) (Pane, error) {
+		var options lightPaneOptions
+		if err := ctx.Config.DecodeOptions(&options); err != nil {
+			return nil, err
+		}
+
+		offImage, err := ctx.Image("offImage", ctx.Config.OffImage)
+		if err != nil {
+			return nil, err
+		}
+
+		onImage, err := ctx.Image("onImage", ctx.Config.OnImage)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewLightPane(options.ColorMode, offImage, onImage, ctx.Conn), nil
+	})
+}
+
 func NewLightPane(colorMode bool /*onOffDevices *[]*ninja.ServiceClient, airwheelDevices *[]*ninja.ServiceClient,*/, offImage string, onImage string, conn *ninja.Connection) *LightPane {
 
 	name := "BrightnessPane"
@@ -71,7 +96,7 @@ func NewLightPane(colorMode bool /*onOffDevices *[]*ninja.ServiceClient, airwhee
 		log:              log,
 		conn:             conn,
 		airWheelThrottle: &throttle{delay: colorInterval},
-		lastTap:          time.Now(),
+		lastTap:          now(),
 		gestureSync:      &sync.Mutex{},
 	}
 
@@ -85,7 +110,7 @@ func NewLightPane(colorMode bool /*onOffDevices *[]*ninja.ServiceClient, airwhee
 		getChannelServicesContinuous("light", "on-off", /*func(thing *model.Thing) bool {
 			isAccent := strings.Contains(strings.ToLower(thing.Name), "accent")
 			return isAccent == demoAccentMode
-			}*/nil, func(clients []*ninja.ServiceClient, err error) {
+			}*/nil, func(clients []*Device, err error) {
 				if err != nil {
 					log.Infof("Failed to update on-off devices: %s", err)
 				} else {
@@ -99,7 +124,7 @@ func NewLightPane(colorMode bool /*onOffDevices *[]*ninja.ServiceClient, airwhee
 							device.OnEvent("state", func(state *bool, topicKeys map[string]string) bool {
 								log.Debugf("Got on-off state: %t", *state)
 								// Ignore state updates if its within 500ms of a tap (which will update the display)
-								if time.Since(pane.lastTap) > 500*time.Millisecond {
+								if since(pane.lastTap) > 500*time.Millisecond {
 									pane.onOffState = *state
 								}
 
@@ -115,7 +140,7 @@ func NewLightPane(colorMode bool /*onOffDevices *[]*ninja.ServiceClient, airwhee
 	getChannelServicesContinuous("light", "core/batching", /*func(thing *model.Thing) bool {
 		isAccent := strings.Contains(strings.ToLower(thing.Name), "accent")
 		return isAccent == demoAccentMode
-		}*/nil, func(clients []*ninja.ServiceClient, err error) {
+		}*/nil, func(clients []*Device, err error) {
 			if err != nil {
 				log.Infof("Failed to update batching devices: %s", err)
 			} else {
@@ -126,7 +151,7 @@ func NewLightPane(colorMode bool /*onOffDevices *[]*ninja.ServiceClient, airwhee
 	//}
 
 	if colorMode {
-		getChannelServicesContinuous("light", "color", nil, func(clients []*ninja.ServiceClient, err error) {
+		getChannelServicesContinuous("light", "color", nil, func(clients []*Device, err error) {
 			if err != nil {
 				log.Infof("Failed to update color devices: %s", err)
 			} else {
@@ -143,7 +168,7 @@ func NewLightPane(colorMode bool /*onOffDevices *[]*ninja.ServiceClient, airwhee
 							}
 
 							// Ignore state updates if its within 2s of an airwheel (which will update the display)
-							if time.Since(pane.lastAirWheelTime) > time.Second {
+							if since(pane.lastAirWheelTime) > time.Second {
 								pane.airWheelState = *state.Hue
 							}
 
@@ -154,7 +179,7 @@ func NewLightPane(colorMode bool /*onOffDevices *[]*ninja.ServiceClient, airwhee
 			}
 		})
 	} else {
-		getChannelServicesContinuous("light", "brightness", nil, func(clients []*ninja.ServiceClient, err error) {
+		getChannelServicesContinuous("light", "brightness", nil, func(clients []*Device, err error) {
 			if err != nil {
 				log.Infof("Failed to update brightness devices: %s", err)
 			} else {
@@ -166,7 +191,7 @@ func NewLightPane(colorMode bool /*onOffDevices *[]*ninja.ServiceClient, airwhee
 							log.Infof("Got brightness state: %f", *state)
 
 							// Ignore state updates if its within 2s of an airwheel (which will update the display)
-							if time.Since(pane.lastAirWheelTime) > time.Second {
+							if since(pane.lastAirWheelTime) > time.Second {
 								pane.airWheelState = *state
 							}
 
@@ -194,13 +219,13 @@ func (p *LightPane) Gesture(gesture *gestic.GestureMessage) {
 	p.gestureSync.Lock()
 	defer p.gestureSync.Unlock()
 
-	if !p.colorMode && gesture.Tap.Active() && time.Since(p.lastTap) > lightTapInterval {
-		p.lastTap = time.Now()
+	if !p.colorMode && gesture.Tap.Active() && since(p.lastTap) > lightTapInterval {
+		p.lastTap = now()
 
 		p.SetOnOffState(!p.onOffState)
 	}
 
-	if time.Since(gesture.Time) > time.Millisecond*100 {
+	if since(gesture.Time) > time.Millisecond*100 {
 		// Too old for wheeling, don't care
 		return
 	}
@@ -228,11 +253,11 @@ func (p *LightPane) Gesture(gesture *gestic.GestureMessage) {
 		/*x, _ := json.Marshal(gesture)
 		p.log.Infof("wheel %s", x)*/
 
-		if time.Since(p.lastAirWheelTime) > time.Millisecond*300 {
+		if since(p.lastAirWheelTime) > time.Millisecond*300 {
 			p.lastAirWheel = nil
 		}
 
-		p.lastAirWheelTime = time.Now()
+		p.lastAirWheelTime = now()
 
 		//p.log.Debugf("Airwheel: %d", gesture.AirWheel.AirWheelVal)
 
@@ -397,18 +422,14 @@ func (p *LightPane) IsDirty() bool {
 
 type throttle struct {
 	delay time.Duration
-	busy  bool
+	last  time.Time
 }
 
 func (t *throttle) try() bool {
-	if t.busy {
+	if since(t.last) < t.delay {
 		return false
 	}
 
-	t.busy = true
-	go func() {
-		time.Sleep(t.delay)
-		t.busy = false
-	}()
+	t.last = now()
 	return true
 }
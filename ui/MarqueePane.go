@@ -0,0 +1,212 @@
+package ui
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ninjasphere/gestic-tools/go-gestic-sdk"
+	"github.com/ninjasphere/pixfont"
+	"github.com/ninjasphere/sphere-go-led-controller/fonts/O4b03b"
+	"github.com/ninjasphere/sphere-go-led-controller/fonts/clock"
+)
+
+// Fonts are the pixel fonts text can be shown in. The clock font only has digits and
+// colons.
+var Fonts = map[string]*pixfont.PixFont{
+	"O4b03b": O4b03b.Font,
+	"clock":  clock.Font,
+}
+
+// FontNames answers the names of the fonts, sorted.
+func FontNames() []string {
+	var names []string
+	for name := range Fonts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// The height of a line of text in each of the fonts, including the gap below it
+const lineHeight = 6
+
+// The most lines of text that fit on the display
+const maxLines = (height + 1) / lineHeight
+
+// How far scrolling text moves each second (in pixels) unless it's told otherwise
+const defaultScrollSpeed = 15
+
+// MarqueeOptions are how a MarqueePane shows its text. The zero value is white text in
+// the O4b03b font, centred, scrolling forever at the default speed.
+type MarqueeOptions struct {
+	Color  color.Color
+	Font   string
+	Speed  float64 // pixels a second
+	Repeat int     // the number of times scrolling text goes past, or 0 for forever
+	Align  string  // left, center or right, for lines that fit
+}
+
+type marqueeLine struct {
+	text  string
+	width int
+}
+
+// MarqueePane shows a line or two of text. Lines that fit on the display are aligned,
+// and lines that don't scroll in from the right until they have gone off the left.
+type MarqueePane struct {
+	lines   []marqueeLine
+	font    *pixfont.PixFont
+	color   color.Color
+	speed   float64
+	repeat  int
+	align   string
+	top     int
+	cycle   int // how far the scrolling lines move before they start again
+	start   time.Time
+	settled bool // a frame has been rendered since the text stopped moving
+}
+
+// NewMarqueePane answers a pane showing text, with lines separated by newlines.
+func NewMarqueePane(text string, options MarqueeOptions) (*MarqueePane, error) {
+	if options.Font == "" {
+		options.Font = "O4b03b"
+	}
+	if options.Align == "" {
+		options.Align = "center"
+	}
+	if options.Color == nil {
+		options.Color = color.White
+	}
+	if options.Speed == 0 {
+		options.Speed = defaultScrollSpeed
+	}
+
+	font, ok := Fonts[options.Font]
+	if !ok {
+		return nil, fmt.Errorf("Unknown font '%s'. Try one of %s", options.Font, strings.Join(FontNames(), ", "))
+	}
+
+	switch {
+	case options.Align != "left" && options.Align != "center" && options.Align != "right":
+		return nil, fmt.Errorf("Unknown alignment '%s'. Try left, center or right", options.Align)
+	case options.Speed < 0:
+		return nil, fmt.Errorf("Bad scroll speed: %g", options.Speed)
+	case options.Repeat < 0:
+		return nil, fmt.Errorf("Bad repeat count: %d", options.Repeat)
+	case text == "":
+		return nil, fmt.Errorf("There's no text to show")
+	}
+
+	texts := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if len(texts) > maxLines {
+		return nil, fmt.Errorf("Only %d lines of text fit on the display, not %d", maxLines, len(texts))
+	}
+
+	pane := &MarqueePane{
+		font:   font,
+		color:  options.Color,
+		speed:  options.Speed,
+		repeat: options.Repeat,
+		align:  options.Align,
+		top:    (height + 1 - len(texts)*lineHeight) / 2,
+		start:  now(),
+	}
+
+	scratch := image.NewRGBA(image.Rect(0, 0, width, height))
+	for _, text := range texts {
+		line := marqueeLine{
+			text:  text,
+			width: font.DrawString(scratch, 0, 0, text, color.Black),
+		}
+		if line.width > width && line.width+width > pane.cycle {
+			pane.cycle = line.width + width
+		}
+		pane.lines = append(pane.lines, line)
+	}
+
+	return pane, nil
+}
+
+// Duration answers how long the text scrolls for, or 0 if it doesn't stop (or doesn't
+// scroll at all).
+func (p *MarqueePane) Duration() time.Duration {
+	if p.repeat == 0 || p.cycle == 0 {
+		return 0
+	}
+	return time.Duration(float64(p.repeat*p.cycle) / p.speed * float64(time.Second))
+}
+
+// moving answers how far the text has scrolled, and whether it is still scrolling.
+func (p *MarqueePane) moving() (int, bool) {
+	if p.cycle == 0 {
+		return 0, false
+	}
+
+	offset := int(since(p.start).Seconds() * p.speed)
+	if p.repeat > 0 && offset >= p.repeat*p.cycle {
+		return 0, false
+	}
+	return offset, true
+}
+
+func (p *MarqueePane) aligned(lineWidth int) int {
+	switch p.align {
+	case "left":
+		return 0
+	case "right":
+		return width - lineWidth
+	default:
+		return (width - lineWidth) / 2
+	}
+}
+
+func (p *MarqueePane) IsEnabled() bool {
+	return true
+}
+
+func (p *MarqueePane) KeepAwake() bool {
+	return false
+}
+
+func (p *MarqueePane) Gesture(gesture *gestic.GestureMessage) {
+}
+
+func (p *MarqueePane) Render() (*image.RGBA, error) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	offset, moving := p.moving()
+
+	for i, line := range p.lines {
+		x := p.aligned(line.width)
+		if line.width > width {
+			// Once it has stopped, it's shown from the start
+			x = 0
+			if moving {
+				x = width - offset%p.cycle
+			}
+		}
+
+		p.font.DrawString(img, x, p.top+i*lineHeight, line.text, p.color)
+	}
+
+	p.settled = !moving
+
+	return img, nil
+}
+
+func (p *MarqueePane) IsDirty() bool {
+	_, moving := p.moving()
+	return moving || !p.settled
+}
+
+// FrameRate is the scroll speed, so the text moves a pixel each frame
+func (p *MarqueePane) FrameRate() float64 {
+	if _, moving := p.moving(); moving {
+		return p.speed
+	}
+	return 1
+}
@@ -16,10 +16,10 @@ import (
 	"github.com/ninjasphere/go-ninja/logger"
 )
 
-var volumeModeReset = config.MustDuration("led.media.volumeModeReset")
-var mediaTapTimeout = config.MustDuration("led.media.tapInterval")
-var volumeInterval = config.MustDuration("led.media.volumeInterval")
-var airWheelReset = config.MustDuration("led.media.airWheelReset")
+var volumeModeReset = config.Duration(time.Second*3, "led.media.volumeModeReset")
+var mediaTapTimeout = config.Duration(time.Second, "led.media.tapInterval")
+var volumeInterval = config.Duration(time.Millisecond*100, "led.media.volumeInterval")
+var airWheelReset = config.Duration(time.Millisecond*500, "led.media.airWheelReset")
 
 type MediaPane struct {
 	log  *logger.Logger
@@ -53,8 +53,8 @@ type MediaPane struct {
 
 	gestureSync *sync.Mutex
 
-	controlDevices []*ninja.ServiceClient
-	volumeDevices  []*ninja.ServiceClient
+	controlDevices []*Device
+	volumeDevices  []*Device
 }
 
 type MediaPaneImages struct {
@@ -69,14 +69,20 @@ type MediaPaneImages struct {
 }
 
 var mediaImages = MediaPaneImages{
-	Volume:     util.ResolveImagePath(config.MustString("led.media.images.volume")),
-	VolumeUp:   util.ResolveImagePath(config.MustString("led.media.images.volumeUp")),
-	VolumeDown: util.ResolveImagePath(config.MustString("led.media.images.volumeDown")),
-	Mute:       util.ResolveImagePath(config.MustString("led.media.images.mute")),
-	Play:       util.ResolveImagePath(config.MustString("led.media.images.play")),
-	Pause:      util.ResolveImagePath(config.MustString("led.media.images.pause")),
-	Stop:       util.ResolveImagePath(config.MustString("led.media.images.stop")),
-	Next:       util.ResolveImagePath(config.MustString("led.media.images.next")),
+	Volume:     util.ResolveImagePath(config.String("media-volume-speaker.png", "led.media.images.volume")),
+	VolumeUp:   util.ResolveImagePath(config.String("media-volume-up.png", "led.media.images.volumeUp")),
+	VolumeDown: util.ResolveImagePath(config.String("media-volume-down.png", "led.media.images.volumeDown")),
+	Mute:       util.ResolveImagePath(config.String("media-volume-mute.png", "led.media.images.mute")),
+	Play:       util.ResolveImagePath(config.String("media-play.png", "led.media.images.play")),
+	Pause:      util.ResolveImagePath(config.String("media-pause.png", "led.media.images.pause")),
+	Stop:       util.ResolveImagePath(config.String("media-stop.png", "led.media.images.stop")),
+	Next:       util.ResolveImagePath(config.String("media-next.png", "led.media.images.next")),
+}
+
+func init() {
+	RegisterPane("media", func(ctx *PaneContext) (Pane, error) {
+		return NewMediaPane(ctx.Conn), nil
+	})
 }
 
 func NewMediaPane(conn *ninja.Connection) *MediaPane {
@@ -98,7 +104,7 @@ func NewMediaPane(conn *ninja.Connection) *MediaPane {
 
 		playingState: "stopped",
 
-		lastVolumeTime: time.Now(),
+		lastVolumeTime: now(),
 		//lastAirWheelTime: time.Now(),
 	}
 
@@ -114,7 +120,7 @@ func NewMediaPane(conn *ninja.Connection) *MediaPane {
 
 	listening := make(map[string]bool)
 
-	getChannelServicesContinuous("mediaplayer", "media-control", nil, func(devices []*ninja.ServiceClient, err error) {
+	getChannelServicesContinuous("mediaplayer", "media-control", nil, func(devices []*Device, err error) {
 
 		if err != nil {
 			log.Infof("Failed to update control devices: %s", err)
@@ -146,7 +152,7 @@ func NewMediaPane(conn *ninja.Connection) *MediaPane {
 
 	})
 
-	getChannelServicesContinuous("mediaplayer", "volume", nil, func(devices []*ninja.ServiceClient, err error) {
+	getChannelServicesContinuous("mediaplayer", "volume", nil, func(devices []*Device, err error) {
 		if err != nil {
 			log.Infof("Failed to update volume devices: %s", err)
 		} else {
@@ -172,7 +178,7 @@ func NewMediaPane(conn *ninja.Connection) *MediaPane {
 					pane.log.Infof("Got new volume device: %s supported: %v", device.Topic, device.SupportedMethods)
 
 					device.OnEvent("state", func(params *json.RawMessage, values map[string]string) bool {
-						if time.Since(pane.lastVolumeTime) > time.Millisecond*500 {
+						if since(pane.lastVolumeTime) > time.Millisecond*500 {
 
 							var volume channels.VolumeState
 							err := json.Unmarshal(*params, &volume)
@@ -240,7 +246,7 @@ func (p *MediaPane) Gesture(gesture *gestic.GestureMessage) {
 		p.volumeMode = true
 		p.volumeModeReset.Reset(volumeModeReset)
 
-		if time.Since(p.lastAirWheelTime) > airWheelReset {
+		if since(p.lastAirWheelTime) > airWheelReset {
 			p.lastAirWheel = nil
 		}
 
@@ -250,7 +256,7 @@ func (p *MediaPane) Gesture(gesture *gestic.GestureMessage) {
 
 		p.countSinceLast = gesture.AirWheel.CountSinceLast
 
-		p.lastAirWheelTime = time.Now()
+		p.lastAirWheelTime = now()
 
 		p.log.Debugf("Airwheel: %d", gesture.AirWheel.Counter)
 
@@ -273,10 +279,10 @@ func (p *MediaPane) Gesture(gesture *gestic.GestureMessage) {
 
 			if p.volumeUpDownMode {
 
-				if time.Since(p.lastVolumeTime) < volumeInterval {
+				if since(p.lastVolumeTime) < volumeInterval {
 					p.log.Debugf("Volume rate limited")
 				} else {
-					p.lastVolumeTime = time.Now()
+					p.lastVolumeTime = now()
 					p.log.Debugf("Volume NOT rate limited")
 					dir := offset > 0
 					p.volumeUpDown = &dir
@@ -297,10 +303,10 @@ func (p *MediaPane) Gesture(gesture *gestic.GestureMessage) {
 				p.volume = volume
 
 				if p.lastSentVolume != volume {
-					if time.Since(p.lastVolumeTime) < volumeInterval {
+					if since(p.lastVolumeTime) < volumeInterval {
 						p.log.Debugf("Volume rate limited")
 					} else {
-						p.lastVolumeTime = time.Now()
+						p.lastVolumeTime = now()
 						p.log.Debugf("Volume NOT rate limited")
 						go p.SendVolume()
 					}
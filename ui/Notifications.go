@@ -0,0 +1,153 @@
+package ui
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ninjasphere/go-ninja/config"
+	"github.com/ninjasphere/sphere-go-led-controller/util"
+)
+
+var defaultNotificationTime = config.Duration(time.Second*5, "led.notifications.displayTime")
+var attentionDuration = config.Duration(time.Millisecond*1500, "led.notifications.attentionTime")
+
+// The time between the pulses of a notification asking for attention
+const attentionPulse = time.Millisecond * 500
+
+// Notification is shown in place of the current pane of a PaneLayout, until its
+// DisplayTime is up or it is dismissed with a tap.
+type Notification struct {
+	Pane        Pane
+	Priority    int           // higher priorities are shown first, and interrupt lower ones
+	DisplayTime time.Duration // or defaultNotificationTime if 0
+	Attention   bool          // pulse when first shown, to catch the eye
+
+	shown time.Time // when it was shown, or zero while it's waiting
+}
+
+// NewNotificationPane answers a pane showing an icon from the images directory, or some
+// (scrolling) text in col, or if there's neither, a solid col.
+func NewNotificationPane(icon string, text string, col color.Color) (Pane, error) {
+	switch {
+	case icon != "" && text != "":
+		return nil, fmt.Errorf("A notification can have an icon or text, but not both")
+	case icon != "":
+		return OpenImagePane(util.ResolveImagePath(icon))
+	case text != "":
+		marquee, err := NewMarqueePane(text, MarqueeOptions{Color: col})
+		if err != nil {
+			return nil, err
+		}
+		return marquee, nil
+	default:
+		return NewColorPane(col), nil
+	}
+}
+
+// attention answers the brightness of a notification's frame, which pulses while it is
+// asking for attention.
+func (n *Notification) attention() (float64, bool) {
+	elapsed := since(n.shown)
+	if !n.Attention || elapsed >= attentionDuration {
+		return 1, false
+	}
+	return 0.6 + 0.4*math.Cos(2*math.Pi*float64(elapsed)/float64(attentionPulse)), true
+}
+
+func (n *Notification) displayTime() time.Duration {
+	if n.DisplayTime <= 0 {
+		return defaultNotificationTime
+	}
+	return n.DisplayTime
+}
+
+// notificationQueue holds the notifications waiting to be shown, highest priority
+// first. The one at the front is being shown.
+type notificationQueue struct {
+	sync.Mutex
+	queue []*Notification
+}
+
+// add queues a notification behind any of the same or higher priority. If it goes in
+// front of the one being shown, that one is shown again from the start later.
+func (q *notificationQueue) add(n *Notification) {
+	q.Lock()
+	defer q.Unlock()
+
+	i := 0
+	for i < len(q.queue) && q.queue[i].Priority >= n.Priority {
+		i++
+	}
+
+	if i == 0 && len(q.queue) > 0 {
+		q.queue[0].shown = time.Time{}
+	}
+
+	n.shown = time.Time{}
+	q.queue = append(q.queue, nil)
+	copy(q.queue[i+1:], q.queue[i:])
+	q.queue[i] = n
+}
+
+// current answers the notification to show, or nil if there isn't one, dropping any
+// that have been shown for long enough. expired is set if any were dropped.
+func (q *notificationQueue) current() (n *Notification, expired bool) {
+	q.Lock()
+	defer q.Unlock()
+
+	for len(q.queue) > 0 {
+		n = q.queue[0]
+		if n.shown.IsZero() {
+			n.shown = now()
+			return n, expired
+		}
+		if since(n.shown) < n.displayTime() {
+			return n, expired
+		}
+		q.queue = q.queue[1:]
+		expired = true
+	}
+
+	return nil, expired
+}
+
+// peek answers the notification at the front, or nil if there isn't one, without
+// showing it or dropping it. due is set if it's waiting to be shown, or has been shown
+// for long enough, so the next frame is needed straight away.
+func (q *notificationQueue) peek() (n *Notification, due bool) {
+	q.Lock()
+	defer q.Unlock()
+
+	if len(q.queue) == 0 {
+		return nil, false
+	}
+	n = q.queue[0]
+	return n, n.shown.IsZero() || since(n.shown) >= n.displayTime()
+}
+
+// dismiss drops the notification being shown, answering whether there was one.
+func (q *notificationQueue) dismiss() bool {
+	q.Lock()
+	defer q.Unlock()
+
+	if len(q.queue) == 0 {
+		return false
+	}
+	q.queue = q.queue[1:]
+	return true
+}
+
+func (q *notificationQueue) showing() bool {
+	q.Lock()
+	defer q.Unlock()
+	return len(q.queue) > 0
+}
+
+func (q *notificationQueue) len() int {
+	q.Lock()
+	defer q.Unlock()
+	return len(q.queue)
+}
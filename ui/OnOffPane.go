@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"fmt"
 	"image"
 	"time"
 
@@ -11,13 +12,13 @@ import (
 	"github.com/ninjasphere/sphere-go-led-controller/util"
 )
 
-var onOffTapTimeout = config.MustDuration("led.onoff.tapTimeout")
+var onOffTapTimeout = config.Duration(time.Millisecond*500, "led.onoff.tapTimeout")
 
 type OnOffPane struct {
 	log  *logger.Logger
 	conn *ninja.Connection
 
-	devices []*ninja.ServiceClient
+	devices []*Device
 
 	state         bool
 	onStateChange func(bool)
@@ -25,9 +26,33 @@ type OnOffPane struct {
 	onImage  util.Image
 	offImage util.Image
 
+	rendered      bool
+	renderedState bool // the state shown by the last frame rendered
+
 	lastTap time.Time
+}
+
+func init() {
+	RegisterPane("onoff", func(ctx *PaneContext) (Pane, error) {
+		thingType := ctx.Config.ThingType
+		if thingType == "" {
+			return nil, fmt.Errorf("onoff panes need a thingType")
+		}
 
-	ignoringGestures bool
+		offImage, err := ctx.Image("offImage", ctx.Config.OffImage)
+		if err != nil {
+			return nil, err
+		}
+
+		onImage, err := ctx.Image("onImage", ctx.Config.OnImage)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewOnOffPane(offImage, onImage, func(state bool) {
+			log.Debugf("%s state: %t", thingType, state)
+		}, ctx.Conn, thingType), nil
+	})
 }
 
 func NewOnOffPane(offImage string, onImage string, onStateChange func(bool), conn *ninja.Connection, thingType string) *OnOffPane {
@@ -39,13 +64,13 @@ func NewOnOffPane(offImage string, onImage string, onStateChange func(bool), con
 		offImage:      util.LoadImage(offImage),
 		onStateChange: onStateChange,
 		log:           log,
-		devices:       make([]*ninja.ServiceClient, 0),
+		devices:       make([]*Device, 0),
 		conn:          conn,
 	}
 
 	listening := make(map[string]bool)
 
-	getChannelServicesContinuous(thingType, "on-off", nil, func(clients []*ninja.ServiceClient, err error) {
+	getChannelServicesContinuous(thingType, "on-off", nil, func(clients []*Device, err error) {
 		if err != nil {
 			log.Infof("Failed to update devices: %s", err)
 		} else {
@@ -59,7 +84,7 @@ func NewOnOffPane(offImage string, onImage string, onStateChange func(bool), con
 					device.OnEvent("state", func(state *bool, topicKeys map[string]string) bool {
 						log.Debugf("Got on-off state: %t", *state)
 
-						if time.Since(pane.lastTap) > 1*time.Second {
+						if since(pane.lastTap) > 1*time.Second {
 							pane.state = *state
 						}
 
@@ -82,21 +107,15 @@ func (p *OnOffPane) KeepAwake() bool {
 }
 
 func (p *OnOffPane) Gesture(gesture *gestic.GestureMessage) {
-	if p.ignoringGestures {
+	if since(p.lastTap) < onOffTapTimeout {
+		// Ignoring gestures for a moment after a tap
 		return
 	}
 
 	if gesture.Tap.Active() {
 		p.log.Infof("Tap!")
 
-		p.lastTap = time.Now()
-
-		p.ignoringGestures = true
-
-		go func() {
-			time.Sleep(onOffTapTimeout)
-			p.ignoringGestures = false
-		}()
+		p.lastTap = now()
 
 		p.SetState(!p.state)
 	}
@@ -115,12 +134,28 @@ func (p *OnOffPane) SetState(state bool) {
 }
 
 func (p *OnOffPane) Render() (*image.RGBA, error) {
+	p.rendered = true
+	p.renderedState = p.state
+
 	if p.state {
 		return p.onImage.GetNextFrame(), nil
 	}
 	return p.offImage.GetNextFrame(), nil
 }
 
+func (p *OnOffPane) FrameRate() float64 {
+	if p.state {
+		return util.ImageFrameRate(p.onImage)
+	}
+	return util.ImageFrameRate(p.offImage)
+}
+
 func (p *OnOffPane) IsDirty() bool {
-	return true
+	if !p.rendered || p.state != p.renderedState {
+		return true
+	}
+	if p.state {
+		return util.IsImageDirty(p.onImage)
+	}
+	return util.IsImageDirty(p.offImage)
 }
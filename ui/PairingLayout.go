@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 
@@ -14,8 +15,9 @@ import (
 type PairingLayout struct {
 	progressPane *UpdateProgressPane
 	currentPane  Pane
+	renderedPane Pane // the pane shown by the last frame rendered
 	log          *logger.Logger
-	drawing      *image.RGBA
+	drawing      *Drawing
 }
 
 func NewPairingLayout() *PairingLayout {
@@ -24,7 +26,9 @@ func NewPairingLayout() *PairingLayout {
 		log:          logger.GetLogger("PaneLayout"),
 		progressPane: NewUpdateProgressPane(util.ResolveImagePath("update-progress.gif"), util.ResolveImagePath("update-loop.gif")),
 	}
-	layout.ShowIcon(config.String("spinner-blue.gif", "led.loadingImage"))
+	if err := layout.ShowIcon(config.String("spinner-blue.gif", "led.loadingImage")); err != nil {
+		layout.log.Warningf("Failed to show the loading image: %s", err)
+	}
 
 	/*	go func() {
 		time.Sleep(time.Second * 5)
@@ -78,8 +82,25 @@ func (l *PairingLayout) ShowCode(text string) {
 	l.currentPane = NewPairingCodePane(text)
 }
 
-func (l *PairingLayout) ShowIcon(image string) {
-	l.currentPane = NewImagePane(util.ResolveImagePath(image))
+// ShowPane shows any pane, e.g. a stream of frames.
+func (l *PairingLayout) ShowPane(pane Pane) {
+	l.currentPane = pane
+}
+
+func (l *PairingLayout) ShowMarquee(marquee *MarqueePane) {
+	l.currentPane = marquee
+}
+
+func (l *PairingLayout) ShowIcon(image string) error {
+	pane, err := OpenImagePane(util.ResolveImagePath(image))
+	if err != nil {
+		l.currentPane = &ImagePane{
+			image: util.MissingImage(),
+		}
+		return err
+	}
+	l.currentPane = pane
+	return nil
 }
 
 func (l *PairingLayout) ShowUpdateProgress(progress float64) {
@@ -87,27 +108,79 @@ func (l *PairingLayout) ShowUpdateProgress(progress float64) {
 	l.currentPane = l.progressPane
 }
 
+// ShowDrawing shows a blank drawing, which shows what's drawn on it straight away.
 func (l *PairingLayout) ShowDrawing() {
-	l.drawing = image.NewRGBA(image.Rect(0, 0, 16, 16))
-	l.currentPane = &ImagePane{
-		image: util.NewSingleImage(l.drawing),
+	l.ShowNewDrawing(false)
+}
+
+// ShowNewDrawing shows a blank drawing. If it's double buffered, what's drawn on it
+// isn't shown until it's committed.
+func (l *PairingLayout) ShowNewDrawing(doubleBuffered bool) {
+	l.drawing = NewDrawing(doubleBuffered)
+	l.currentPane = l.drawing
+}
+
+// Drawing answers the last drawing shown, which can still be drawn on while something
+// else is being shown.
+func (l *PairingLayout) Drawing() (*Drawing, error) {
+	if l.drawing == nil {
+		return nil, fmt.Errorf("There's no drawing. Call displayDrawing first.")
 	}
+	return l.drawing, nil
 }
 
-func (l *PairingLayout) Draw(updates *[][]uint8) {
-	for _, update := range *updates {
-		offset := l.drawing.PixOffset(int(update[0]), int(update[1]))
-		l.drawing.Pix[offset] = update[2]   // R
-		l.drawing.Pix[offset+1] = update[3] // G
-		l.drawing.Pix[offset+2] = update[4] // B
-		l.drawing.Pix[offset+3] = 255       // A
+// Draw sets pixels of the drawing from x,y,r,g,b tuples.
+func (l *PairingLayout) Draw(updates *[][]uint8) error {
+	drawing, err := l.Drawing()
+	if err != nil {
+		return err
+	}
+	if updates == nil {
+		return fmt.Errorf("There are no pixels to draw")
 	}
+	return drawing.SetPixels(*updates)
+}
+
+// PairingState is what a PairingLayout is showing, so it can be shown again later.
+type PairingState struct {
+	pane    Pane
+	drawing *Drawing
+}
+
+// State answers what the layout is showing.
+func (l *PairingLayout) State() PairingState {
+	return PairingState{
+		pane:    l.currentPane,
+		drawing: l.drawing,
+	}
+}
+
+// Restore shows what the layout was showing when state was taken.
+func (l *PairingLayout) Restore(state PairingState) {
+	l.currentPane = state.pane
+	l.drawing = state.drawing
 }
 
 func (l *PairingLayout) Render() (*image.RGBA, error) {
+	l.renderedPane = l.currentPane
+
 	if l.currentPane != nil {
 		return l.currentPane.Render()
 	}
 
 	return &image.RGBA{}, nil
 }
+
+// IsDirty answers whether the next frame rendered may be different to the last one.
+func (l *PairingLayout) IsDirty() bool {
+	pane := l.currentPane
+	return pane != l.renderedPane || pane == nil || isDirty(pane)
+}
+
+// FrameRate answers how many frames a second the current pane needs, or 0 for as many as possible.
+func (l *PairingLayout) FrameRate() float64 {
+	if l.currentPane == nil {
+		return 0
+	}
+	return frameRate(l.currentPane)
+}
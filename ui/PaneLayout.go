@@ -15,15 +15,18 @@ import (
 	"github.com/ninjasphere/go-ninja/api"
 	"github.com/ninjasphere/go-ninja/config"
 	"github.com/ninjasphere/go-ninja/logger"
+	"github.com/ninjasphere/sphere-go-led-controller/gestures"
+	ledmodel "github.com/ninjasphere/sphere-go-led-controller/model"
+	"github.com/ninjasphere/sphere-go-led-controller/util"
 )
 
 const width = 16
 const height = 16
 
-var panDuration = config.MustDuration("led.panDuration")
-var wakeTransitionDuration = config.MustDuration("led.wakeTransition")
-var sleepTransitionDuration = config.MustDuration("led.sleepTransition")
-var sleepTimeout = config.MustDuration("led.sleepTimeout")
+var panDuration = config.Duration(time.Millisecond*350, "led.panDuration")
+var wakeTransitionDuration = config.Duration(time.Millisecond*250, "led.wakeTransition")
+var sleepTransitionDuration = config.Duration(time.Second, "led.sleepTransition")
+var sleepTimeout = config.Duration(time.Second*30, "led.sleepTimeout")
 var forceAllPanes = config.Bool(false, "led.forceAllPanes")
 
 var logGestures = config.Bool(false, "led.gestures.log")
@@ -47,16 +50,19 @@ type PaneLayout struct {
 	log *logger.Logger
 
 	gestures *Tick
+
+	changed bool // the panes have changed since the last frame was rendered
+
+	renderErrors int
+
+	notifications notificationQueue
 }
 
-func NewPaneLayout(fakeGestures bool, conn *ninja.Connection) (*PaneLayout, chan (bool)) {
+// NewPaneLayout answers a layout driven by the gestures from source, if it isn't nil.
+func NewPaneLayout(source gestures.Source, conn *ninja.Connection) (*PaneLayout, chan (bool)) {
 
 	// Wait till we're paired and have a site
-	for {
-		config.MustRefresh()
-		if config.HasString("siteId") {
-			break
-		}
+	for !getHome(conn).Paired() {
 		time.Sleep(time.Second * 2)
 	}
 
@@ -66,27 +72,22 @@ func NewPaneLayout(fakeGestures bool, conn *ninja.Connection) (*PaneLayout, chan
 		gestures: &Tick{
 			name: "Gestures/sec",
 		},
-		wake: make(chan bool),
-		log:  logger.GetLogger("PaneLayout"),
+		wake:    make(chan bool),
+		log:     logger.GetLogger("PaneLayout"),
+		changed: true,
 	}
 	pane.gestures.start()
 
-	if !fakeGestures {
-		g, err := gestic.Open()
+	if source != nil {
+		stream, err := source.Gestures()
 
 		if err != nil {
 			pane.log.Warningf("Error enabling gestures: %s\n", err)
 		} else {
-			//defer g.Close()
-
-			pane.log.Infof("Succesfully connected to GestIC device")
-
-			gestures := g.DataStream()
-
 			go func() {
-				for gesture := range gestures {
+				for gesture := range stream {
 					//pane.log.Debugf("Gesture latency: %s", time.Since(gesture.Time).String())
-					go pane.OnGesture(&gesture)
+					go pane.OnGesture(gesture)
 				}
 			}()
 		}
@@ -96,34 +97,16 @@ func NewPaneLayout(fakeGestures bool, conn *ninja.Connection) (*PaneLayout, chan
 	go func() {
 		for {
 			time.Sleep(time.Millisecond * 50)
+			if pane.awake && (pane.currentPaneKeepsAwake() || pane.notifications.showing()) {
+				// Checked here too, as the current pane isn't rendered while it isn't dirty
+				pane.lastGesture = time.Now()
+			}
 			if pane.awake && time.Since(pane.lastGesture) > sleepTimeout {
 				pane.Sleep()
 			}
 		}
 	}()
 
-	/*if fakeGestures {
-		go func() {
-			for {
-				time.Sleep(time.Millisecond * 5000)
-				gesture := gestic.NewGestureData()
-				gesture.Gesture.GestureVal = 2
-				pane.OnGesture(gesture)
-			}
-		}()
-
-		go func() {
-			for {
-				time.Sleep(time.Millisecond * 10)
-				gesture := gestic.NewGestureData()
-				gesture.Coordinates.X = 100
-				gesture.Coordinates.Y = 100
-				gesture.Coordinates.Z = 100
-				pane.OnGesture(gesture)
-			}
-		}()
-	}*/
-
 	return pane, pane.wake
 }
 
@@ -138,6 +121,32 @@ type lockable interface {
 	Locked() bool
 }
 
+// Panes implementing dirtyable are only rendered again when they say they have changed.
+type dirtyable interface {
+	IsDirty() bool
+}
+
+// Panes implementing frameRater say how many frames a second they need.
+type frameRater interface {
+	FrameRate() float64
+}
+
+// frameRate answers how many frames a second a pane needs, or 0 if it doesn't say.
+func frameRate(pane Pane) float64 {
+	if r, ok := pane.(frameRater); ok {
+		return r.FrameRate()
+	}
+	return 0
+}
+
+// isDirty answers whether a pane needs to be rendered again. Panes that can't tell always do.
+func isDirty(pane Pane) bool {
+	if d, ok := pane.(dirtyable); ok {
+		return d.IsDirty()
+	}
+	return true
+}
+
 func (l *PaneLayout) Wake() {
 
 	l.log.Infof("Waking up")
@@ -207,6 +216,14 @@ func (l *PaneLayout) OnGesture(g *gestic.GestureMessage) {
 	// Ignore all gestures while we're fading in or out
 	if l.fadeTween == nil {
 
+		// A tap dismisses a notification, and nothing else gets to the panes under it
+		if l.notifications.showing() {
+			if g.Tap.Active() {
+				l.DismissNotification()
+			}
+			return
+		}
+
 		pane := l.panes[l.currentPane]
 
 		if pane == nil {
@@ -240,6 +257,30 @@ func (l *PaneLayout) OnGesture(g *gestic.GestureMessage) {
 	}
 }
 
+// Notify shows a notification in place of the current pane, waking the display if it's
+// asleep. If another notification is being shown, it waits its turn unless it has a
+// higher priority. The current pane is shown again once the notifications are gone.
+func (l *PaneLayout) Notify(n *Notification) {
+	l.log.Infof("Notification with priority %d", n.Priority)
+
+	l.notifications.add(n)
+	l.changed = true
+	l.lastGesture = time.Now()
+
+	if !l.awake {
+		go l.Wake()
+	}
+}
+
+// DismissNotification stops showing the current notification, showing the next one
+// (if any) instead.
+func (l *PaneLayout) DismissNotification() {
+	if l.notifications.dismiss() {
+		l.log.Infof("Dismissed notification")
+		l.changed = true
+	}
+}
+
 func (l *PaneLayout) Sleep() {
 	l.log.Infof("Going to sleep")
 	l.awake = false
@@ -254,6 +295,21 @@ func (l *PaneLayout) Sleep() {
 
 func (l *PaneLayout) AddPane(pane Pane) {
 	l.panes = append(l.panes, pane)
+	l.changed = true
+}
+
+// SetPanes replaces all the panes, going back to the first one.
+func (l *PaneLayout) SetPanes(panes []Pane) {
+	l.panLock.Lock()
+	defer l.panLock.Unlock()
+	l.renderLock.Lock()
+	defer l.renderLock.Unlock()
+
+	l.panes = panes
+	l.panTween = nil
+	l.currentPane = 0
+	l.targetPane = 0
+	l.changed = true
 }
 
 func (l *PaneLayout) RemovePane(pane Pane) {
@@ -271,13 +327,76 @@ func (l *PaneLayout) RemovePane(pane Pane) {
 			}
 
 			l.panes = append(l.panes[:i], l.panes[i+1:]...)
+			l.changed = true
 			return
 		}
 	}
 }
 
+// IsDirty answers whether the next frame rendered may be different to the last one.
 func (l *PaneLayout) IsDirty() bool {
-	return true
+	if l.changed || !l.awake || l.fadeTween != nil || l.panTween != nil || l.notifications.showing() {
+		return true
+	}
+
+	l.renderLock.Lock()
+	defer l.renderLock.Unlock()
+
+	if l.currentPane >= len(l.panes) {
+		return true
+	}
+
+	return isDirty(l.panes[l.currentPane])
+}
+
+// FrameRate answers how many frames a second the layout needs, or 0 for as many as possible,
+// which it needs while panning or fading.
+func (l *PaneLayout) FrameRate() float64 {
+	if !l.awake || l.fadeTween != nil || l.panTween != nil {
+		return 0
+	}
+
+	// Peeked, as showing and dropping notifications is left to Render
+	if n, due := l.notifications.peek(); n != nil {
+		if due {
+			return 0
+		}
+		if _, pulsing := n.attention(); pulsing {
+			return 0
+		}
+		return frameRate(n.Pane)
+	}
+
+	l.renderLock.Lock()
+	defer l.renderLock.Unlock()
+
+	if l.currentPane >= len(l.panes) || l.panes[l.currentPane] == nil {
+		return 0
+	}
+
+	return frameRate(l.panes[l.currentPane])
+}
+
+// Stats answers the state of the layout, and counts of what it has been doing.
+func (l *PaneLayout) Stats() *ledmodel.LayoutStats {
+	l.renderLock.Lock()
+	defer l.renderLock.Unlock()
+
+	return &ledmodel.LayoutStats{
+		Awake:             l.awake,
+		CurrentPane:       l.currentPane,
+		Panes:             len(l.panes),
+		GesturesPerSecond: l.gestures.perSecond(),
+		RenderErrors:      l.renderErrors,
+		Notifications:     l.notifications.len(),
+	}
+}
+
+func (l *PaneLayout) currentPaneKeepsAwake() bool {
+	l.renderLock.Lock()
+	defer l.renderLock.Unlock()
+
+	return l.currentPane < len(l.panes) && l.panes[l.currentPane] != nil && l.panes[l.currentPane].KeepAwake()
 }
 
 func (l *PaneLayout) Render() (*image.RGBA, chan (bool), error) {
@@ -300,6 +419,8 @@ func (l *PaneLayout) Render() (*image.RGBA, chan (bool), error) {
 	l.renderLock.Lock()
 	defer l.renderLock.Unlock()
 
+	l.changed = false
+
 	var position = 0
 	if l.panTween != nil {
 		var done bool
@@ -321,6 +442,7 @@ func (l *PaneLayout) Render() (*image.RGBA, chan (bool), error) {
 
 	if err != nil {
 		log.Warningf("Pane failed to render. Removing : %s", err)
+		l.renderErrors++
 
 		l.renderLock.Unlock()
 		l.RemovePane(l.panes[l.currentPane])
@@ -342,6 +464,7 @@ func (l *PaneLayout) Render() (*image.RGBA, chan (bool), error) {
 
 		if err != nil {
 			log.Warningf("Target pane failed to render. Removing : %s", err)
+			l.renderErrors++
 
 			l.renderLock.Unlock()
 			l.RemovePane(l.panes[l.targetPane])
@@ -362,6 +485,8 @@ func (l *PaneLayout) Render() (*image.RGBA, chan (bool), error) {
 		draw.Draw(frame, frame.Bounds(), targetImage, image.Point{targetPosition, 0}, draw.Src)
 	}
 
+	frame = l.renderNotification(frame)
+
 	if l.fadeTween != nil {
 		// We're fading in or out...
 
@@ -381,6 +506,35 @@ func (l *PaneLayout) Render() (*image.RGBA, chan (bool), error) {
 	return frame, nil, nil
 }
 
+// renderNotification answers the current notification's frame in place of frame, or
+// frame if there isn't one.
+func (l *PaneLayout) renderNotification(frame *image.RGBA) *image.RGBA {
+	n, expired := l.notifications.current()
+	if expired {
+		// The pane underneath needs rendering again once the notifications are gone
+		l.changed = true
+	}
+	if n == nil {
+		return frame
+	}
+
+	notification, err := n.Pane.Render()
+	if err != nil {
+		log.Warningf("Notification failed to render. Dismissing : %s", err)
+		l.renderErrors++
+		l.DismissNotification()
+		return frame
+	}
+
+	draw.Draw(frame, frame.Bounds(), notification, notification.Bounds().Min, draw.Src)
+
+	if brightness, pulsing := n.attention(); pulsing {
+		frame = util.ScaleBrightness(frame, brightness)
+	}
+
+	return frame
+}
+
 func (l *PaneLayout) findValidPane(delta int) int {
 	target := l.targetPane + delta
 	if target < 0 {
@@ -490,20 +644,34 @@ func easeOutQuint(t float64, b float64, c float64, d float64) float64 {
 }
 
 type Tick struct {
+	sync.Mutex
 	count int
+	last  int // the count for the last whole second
 	name  string
 }
 
 func (t *Tick) tick() {
+	t.Lock()
 	t.count++
+	t.Unlock()
+}
+
+// perSecond answers the count for the last whole second.
+func (t *Tick) perSecond() int {
+	t.Lock()
+	defer t.Unlock()
+	return t.last
 }
 
 func (t *Tick) start() {
 	go func() {
 		for {
 			time.Sleep(time.Second)
+			t.Lock()
 			log.Infof("%s - %d", t.name, t.count)
+			t.last = t.count
 			t.count = 0
+			t.Unlock()
 		}
 	}()
 }
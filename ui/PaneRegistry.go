@@ -0,0 +1,76 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ninjasphere/go-ninja/api"
+	"github.com/ninjasphere/sphere-go-led-controller/util"
+)
+
+// ImageResolver turns an image name from a layout into a path that can be loaded.
+type ImageResolver func(name string) string
+
+// PaneContext is everything a PaneFactory is given to build a pane.
+type PaneContext struct {
+	Conn   *ninja.Connection
+	Config *PaneConfig
+	Images ImageResolver
+}
+
+// Image resolves the named image, returning an error if the name is empty.
+func (c *PaneContext) Image(field string, name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("%s panes need an %s", c.Config.Type, field)
+	}
+	return c.Images(name), nil
+}
+
+// PaneFactory builds a pane from its configuration.
+type PaneFactory func(ctx *PaneContext) (Pane, error)
+
+var paneFactories = make(map[string]PaneFactory)
+var paneFactoriesLock sync.Mutex
+
+// RegisterPane makes a type of pane available to layouts. Pane packages call it
+// from init(), so importing a package is enough to make its panes available.
+func RegisterPane(paneType string, factory PaneFactory) {
+	paneFactoriesLock.Lock()
+	defer paneFactoriesLock.Unlock()
+
+	if _, ok := paneFactories[paneType]; ok {
+		panic(fmt.Sprintf("Pane type '%s' has already been registered", paneType))
+	}
+	paneFactories[paneType] = factory
+}
+
+// PaneTypes answers the names of all the registered types of pane.
+func PaneTypes() []string {
+	paneFactoriesLock.Lock()
+	defer paneFactoriesLock.Unlock()
+
+	types := make([]string, 0, len(paneFactories))
+	for paneType := range paneFactories {
+		types = append(types, paneType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// NewPaneFromConfig builds a pane using the factory registered for its type.
+func NewPaneFromConfig(config *PaneConfig, conn *ninja.Connection) (Pane, error) {
+	paneFactoriesLock.Lock()
+	factory, ok := paneFactories[config.Type]
+	paneFactoriesLock.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("Unknown pane type: '%s'", config.Type)
+	}
+
+	return factory(&PaneContext{
+		Conn:   conn,
+		Config: config,
+		Images: util.ResolveImagePath,
+	})
+}
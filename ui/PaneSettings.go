@@ -0,0 +1,47 @@
+package ui
+
+import "time"
+
+// PaneSettings are the led.* config of the clock, on/off, light and media panes, which
+// are read from the sphere's config (or their defaults) when the package is loaded.
+type PaneSettings struct {
+	ClockAlarm         bool          // led.clock.alarmEnabled
+	ClockFlashTimes    int           // led.clock.alarmFlashTimes
+	ClockFlashInterval time.Duration // led.clock.alarmFlashInterval
+
+	OnOffTapTimeout time.Duration // led.onoff.tapTimeout
+
+	LightTapInterval       time.Duration // led.light.tapInterval
+	LightColorInterval     time.Duration // led.light.colorInterval
+	LightColorSpeed        float64       // led.light.colorSpeed
+	LightBrightnessSpeed   float64       // led.light.brightnessSpeed
+	LightBrightnessMinimum uint8         // led.light.brightnessMinimum
+
+	MediaVolumeModeReset time.Duration   // led.media.volumeModeReset
+	MediaTapInterval     time.Duration   // led.media.tapInterval
+	MediaVolumeInterval  time.Duration   // led.media.volumeInterval
+	MediaAirWheelReset   time.Duration   // led.media.airWheelReset
+	MediaImages          MediaPaneImages // led.media.images, resolved with util.ResolveImagePath
+}
+
+// SetPaneSettings replaces the settings of the panes made afterwards, e.g. so the pane
+// harness renders the same frames whatever the sphere's config is.
+func SetPaneSettings(s PaneSettings) {
+	enableAlarm = s.ClockAlarm
+	alarmFlashTimes = s.ClockFlashTimes * 2
+	alarmFlashInterval = s.ClockFlashInterval
+
+	onOffTapTimeout = s.OnOffTapTimeout
+
+	lightTapInterval = s.LightTapInterval
+	colorInterval = s.LightColorInterval
+	colorAdjustSpeed = s.LightColorSpeed
+	brightnessAdjustSpeed = s.LightBrightnessSpeed
+	brightnessMinimum = s.LightBrightnessMinimum
+
+	volumeModeReset = s.MediaVolumeModeReset
+	mediaTapTimeout = s.MediaTapInterval
+	volumeInterval = s.MediaVolumeInterval
+	airWheelReset = s.MediaAirWheelReset
+	mediaImages = s.MediaImages
+}
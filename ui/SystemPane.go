@@ -35,6 +35,12 @@ type StatusEvent struct {
 	Message string `json:"message"`
 }
 
+func init() {
+	RegisterPane("system", func(ctx *PaneContext) (Pane, error) {
+		return NewSystemPane(ctx.Conn), nil
+	})
+}
+
 func NewSystemPane(conn *ninja.Connection) Pane {
 
 	pane := &SystemPane{
@@ -43,7 +49,7 @@ func NewSystemPane(conn *ninja.Connection) Pane {
 		color: "green",
 	}
 
-	status := conn.GetServiceClient("$device/:deviceId/component/:componentId")
+	status := getHome(conn).Service("$device/:deviceId/component/:componentId")
 	status.OnEvent("status", func(statusEvent *StatusEvent, values map[string]string) bool {
 		if deviceId, ok := values["deviceId"]; !ok {
 			return true
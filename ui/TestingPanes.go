@@ -1,7 +1,6 @@
 package ui
 
 import (
-	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
@@ -17,6 +16,7 @@ type ColorPane struct {
 	color  func() color.Color
 	draw   func()
 	bounds func() image.Rectangle
+	dirty  func() bool // whether the next frame may be different to the last one
 }
 
 func NewColorPane(in color.Color) *ColorPane {
@@ -25,6 +25,9 @@ func NewColorPane(in color.Color) *ColorPane {
 			return in
 		},
 		image: image.NewRGBA(image.Rect(0, 0, width, height)),
+		dirty: func() bool {
+			return false
+		},
 	}
 	pane.draw = func() {
 		draw.Draw(pane.image, pane.bounds(), &image.Uniform{pane.color()}, image.ZP, draw.Src)
@@ -38,13 +41,15 @@ func NewColorPane(in color.Color) *ColorPane {
 func NewFadingColorPane(in color.Color, d time.Duration) *ColorPane {
 
 	pane := NewColorPane(in)
-	start := time.Now()
+	start := now()
+	faded := false // whether the last frame of the fade has been drawn
 	pane.color = func() color.Color {
-		n := time.Now().Sub(start)
+		n := since(start)
 		ratio := 1.0
 		if n < d {
 			ratio = float64(n) / float64(d)
 		}
+		faded = n >= d
 		r, g, b, a := in.RGBA()
 		return color.RGBA{
 			R: uint8(uint16((1.0-ratio)*float64(r)) >> 8),
@@ -53,6 +58,9 @@ func NewFadingColorPane(in color.Color, d time.Duration) *ColorPane {
 			A: uint8(a),
 		}
 	}
+	pane.dirty = func() bool {
+		return !faded
+	}
 	return pane
 }
 
@@ -61,7 +69,7 @@ func NewFadingShrinkingColorPane(in color.Color, d time.Duration) *ColorPane {
 
 	pane := NewFadingColorPane(in, d)
 	basicDraw := pane.draw
-	start := time.Now()
+	start := now()
 	black := color.RGBA{
 		R: 0,
 		G: 0,
@@ -70,7 +78,7 @@ func NewFadingShrinkingColorPane(in color.Color, d time.Duration) *ColorPane {
 	}
 
 	pane.bounds = func() image.Rectangle {
-		n := time.Now().Sub(start)
+		n := since(start)
 		dim := 0
 		if d > n && d > 0 {
 			dim = int(float64(d-n) * 8.0 / float64(d))
@@ -114,72 +122,7 @@ func (p *ColorPane) Render() (*image.RGBA, error) {
 }
 
 func (p *ColorPane) IsDirty() bool {
-	return false
-}
-
-type TextScrollPane struct {
-	text      string
-	textWidth int
-	position  int
-	start     time.Time
-}
-
-func NewTextScrollPane(text string) *TextScrollPane {
-
-	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
-
-	width := O4b03b.Font.DrawString(img, 0, 0, text, color.Black)
-	//log.Infof("Text '%s' width: %d", text, width)
-
-	return &TextScrollPane{
-		text:      text,
-		textWidth: width,
-		position:  17,
-		start:     time.Now(),
-	}
-}
-
-func (p *TextScrollPane) IsEnabled() bool {
-	return true
-}
-
-func (p *TextScrollPane) KeepAwake() bool {
-	return false
-}
-
-func (p *TextScrollPane) Gesture(gesture *gestic.GestureMessage) {
-
-}
-
-func (p *TextScrollPane) Render() (*image.RGBA, error) {
-	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
-
-	p.position = p.position - 1
-	if p.position < -p.textWidth {
-		p.position = 17
-	}
-
-	//log.Printf("Rendering text '%s' at position %d", p.text, p.position)
-
-	O4b03b.Font.DrawString(img, p.position, 0, p.text, color.White)
-
-	elapsed := time.Now().Sub(p.start)
-
-	elapsedSeconds := int(elapsed.Seconds())
-
-	O4b03b.Font.DrawString(img, 0, 5, "Hey! :)", color.RGBA{0, 255, 255, 255})
-
-	O4b03b.Font.DrawString(img, 0, 11, "02", color.RGBA{255, 0, 0, 255})
-
-	O4b03b.Font.DrawString(img, 9, 11, fmt.Sprintf("%0d", elapsedSeconds), color.RGBA{255, 0, 0, 255})
-
-	O4b03b.Font.DrawString(img, 8, 11, ":", color.RGBA{255, 255, 255, 255})
-
-	return img, nil
-}
-
-func (p *TextScrollPane) IsDirty() bool {
-	return true
+	return p.dirty()
 }
 
 type PairingCodePane struct {
@@ -17,15 +17,21 @@ import (
 	"github.com/ninjasphere/sphere-go-led-controller/util"
 )
 
-var enableWeatherPane = config.MustBool("led.weather.enabled")
-var weatherUpdateInterval = config.MustDuration("led.weather.updateInterval")
+var enableWeatherPane = config.Bool(false, "led.weather.enabled")
+var weatherUpdateInterval = config.Duration(time.Minute*15, "led.weather.updateInterval")
 var temperatureDisplayTime = config.Duration(time.Second*5, "led.weather.temperatureDisplayTime")
 
 var globalSite *model.Site
 var timezone *time.Location
 
+// GetSite answers the site (and its timezone) once it has been fetched by the weather
+// pane, or nil if it hasn't been yet.
+func GetSite() (*model.Site, *time.Location) {
+	return globalSite, timezone
+}
+
 type WeatherPane struct {
-	siteModel   *ninja.ServiceClient
+	home        Home
 	site        *model.Site
 	getWeather  *time.Timer
 	tempTimeout *time.Timer
@@ -34,11 +40,17 @@ type WeatherPane struct {
 	image       util.Image
 }
 
+func init() {
+	RegisterPane("weather", func(ctx *PaneContext) (Pane, error) {
+		return NewWeatherPane(ctx.Conn), nil
+	})
+}
+
 func NewWeatherPane(conn *ninja.Connection) *WeatherPane {
 
 	pane := &WeatherPane{
-		siteModel: conn.GetServiceClient("$home/services/SiteModel"),
-		image:     util.LoadImage(util.ResolveImagePath("weather/loading.gif")),
+		home:  getHome(conn),
+		image: util.LoadImage(util.ResolveImagePath("weather/loading.gif")),
 	}
 
 	pane.tempTimeout = time.AfterFunc(0, func() {
@@ -66,8 +78,7 @@ func (p *WeatherPane) GetWeather() {
 	enableWeatherPane = false
 
 	for {
-		site := &model.Site{}
-		err := p.siteModel.Call("fetch", config.MustString("siteId"), site, time.Second*5)
+		site, err := p.home.FetchSite()
 
 		if err == nil && (site.Longitude != nil || site.Latitude != nil) {
 			p.site = site
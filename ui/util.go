@@ -1,7 +1,6 @@
 package ui
 
 import (
-	"encoding/json"
 	"fmt"
 	"time"
 
@@ -10,6 +9,7 @@ import (
 	"github.com/ninjasphere/go-ninja/config"
 	"github.com/ninjasphere/go-ninja/logger"
 	"github.com/ninjasphere/go-ninja/model"
+	"github.com/ninjasphere/sphere-go-led-controller/util"
 )
 
 /*
@@ -35,9 +35,7 @@ type Channel struct {
 
 var sameRoomOnly = config.Bool(true, "homecloud.sameRoomOnly")
 
-var conn *ninja.Connection
 var tasks []*request
-var thingModel *ninja.ServiceClient
 
 var log = logger.GetLogger("ui")
 
@@ -45,7 +43,7 @@ type request struct {
 	thingType string
 	protocol  string
 	filter    func(thing *model.Thing) bool
-	cb        func([]*ninja.ServiceClient, error)
+	cb        func([]*Device, error)
 }
 
 var roomID *string
@@ -79,10 +77,7 @@ var allThings []model.Thing
 
 func fetchAll() error {
 
-	var things []model.Thing
-
-	err := thingModel.Call("fetchAll", []interface{}{}, &things, time.Second*20)
-	//err = client.Call("fetch", "c7ac05e0-9999-4d93-bfe3-a0b4bb5e7e78", &thing)
+	things, err := home.FetchAll()
 
 	if err != nil {
 		return fmt.Errorf("Failed to get things!: %s", err)
@@ -98,22 +93,27 @@ func StartSearchTasks(c *ninja.Connection) {
 	startSearchTasks(c)
 }
 
+// RefreshThings fetches the things in the home now, updating the panes' devices.
+func RefreshThings() error {
+	return fetchAll()
+}
+
+// startSearchTasks finds things using the home set with SetHome, or the ThingModel
+// over c if there isn't one.
 func startSearchTasks(c *ninja.Connection) {
-	conn = c
-	thingModel = conn.GetServiceClient("$home/services/ThingModel")
+	home := getHome(c)
 
 	dirty := false
 
-	setDirty := func(params *json.RawMessage, topicKeys map[string]string) bool {
+	setDirty := func() {
 		log.Infof("Devices added/removed/updated. Marking dirty.")
 		dirty = true
-		return true
 	}
 
 	go func() {
 		for {
 			time.Sleep(time.Second * 30)
-			setDirty(nil, nil)
+			setDirty()
 		}
 	}()
 
@@ -129,9 +129,7 @@ func startSearchTasks(c *ninja.Connection) {
 		}
 	}()
 
-	thingModel.OnEvent("created", setDirty)
-	thingModel.OnEvent("updated", setDirty)
-	thingModel.OnEvent("deleted", setDirty)
+	home.OnChange(setDirty)
 
 	go func() {
 		time.Sleep(time.Second * 10)
@@ -145,11 +143,11 @@ func startSearchTasks(c *ninja.Connection) {
 	}()
 }
 
-func GetChannelServicesContinuous(thingType string, protocol string, filter func(thing *model.Thing) bool, cb func([]*ninja.ServiceClient, error)) {
+func GetChannelServicesContinuous(thingType string, protocol string, filter func(thing *model.Thing) bool, cb func([]*Device, error)) {
 	getChannelServicesContinuous(thingType, protocol, filter, cb)
 }
 
-func getChannelServicesContinuous(thingType string, protocol string, filter func(thing *model.Thing) bool, cb func([]*ninja.ServiceClient, error)) {
+func getChannelServicesContinuous(thingType string, protocol string, filter func(thing *model.Thing) bool, cb func([]*Device, error)) {
 
 	if filter == nil {
 		filter = func(thing *model.Thing) bool {
@@ -162,11 +160,11 @@ func getChannelServicesContinuous(thingType string, protocol string, filter func
 	cb(getChannelServices(thingType, protocol, filter))
 }
 
-func getChannelServices(thingType string, protocol string, filter func(thing *model.Thing) bool) ([]*ninja.ServiceClient, error) {
+func getChannelServices(thingType string, protocol string, filter func(thing *model.Thing) bool) ([]*Device, error) {
 
 	//time.Sleep(time.Second * 3)
 
-	var services []*ninja.ServiceClient
+	var services []*Device
 
 	for _, thing := range allThings {
 		if thing.Type == thingType {
@@ -177,7 +175,7 @@ func getChannelServices(thingType string, protocol string, filter func(thing *mo
 			channel := getChannel(&thing, protocol)
 			if channel != nil {
 				if filter(&thing) {
-					services = append(services, conn.GetServiceClientFromAnnouncement(channel.ServiceAnnouncement))
+					services = append(services, home.Device(channel))
 				}
 			}
 		}
@@ -204,3 +202,12 @@ func getChannel(thing *model.Thing, protocol string) *model.Channel {
 
 	return nil
 }
+
+// now answers the time according to util.DefaultClock, so panes can be driven by a fake clock.
+func now() time.Time {
+	return util.DefaultClock.Now()
+}
+
+func since(t time.Time) time.Duration {
+	return now().Sub(t)
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"bytes"
 	"fmt"
 	"image"
 	"image/color"
@@ -8,9 +9,12 @@ import (
 	"image/gif"
 	"image/jpeg"
 	"image/png"
+	"io"
+	"io/ioutil"
 	"math"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ninjasphere/go-ninja/logger"
@@ -33,6 +37,46 @@ type Image interface {
 	GetPositionFrame(position float64, blend bool) *image.RGBA
 }
 
+// Images implementing dirtyImage know whether GetNextFrame would answer a different frame
+// to the last one it answered.
+type dirtyImage interface {
+	IsDirty() bool
+}
+
+// Images implementing frameRater know how often their frames change.
+type frameRater interface {
+	FrameRate() float64
+}
+
+// Animation is implemented by images whose playback can be controlled, e.g. an
+// AnimatedImage, or a CachedImage of one.
+type Animation interface {
+	Image
+	Pause()
+	Resume()
+	Seek(elapsed time.Duration)
+	Reset()
+	SetClock(clock Clock)
+}
+
+// ImageFrameRate answers how many times a second the frames of img can change, or 0 if
+// it doesn't know.
+func ImageFrameRate(img Image) float64 {
+	if r, ok := img.(frameRater); ok {
+		return r.FrameRate()
+	}
+	return 0
+}
+
+// IsImageDirty answers whether the next frame of img may be different to the last one.
+// Images that can't tell are always dirty.
+func IsImageDirty(img Image) bool {
+	if d, ok := img.(dirtyImage); ok {
+		return d.IsDirty()
+	}
+	return true
+}
+
 type SingleImage struct {
 	frame *image.RGBA
 }
@@ -74,83 +118,225 @@ func (i *MaskImage) GetPositionFrame(position float64, blend bool) *image.RGBA {
 	return i.Image.GetPositionFrame(position, blend)
 }
 
+func (i *MaskImage) IsDirty() bool {
+	return IsImageDirty(i.Image)
+}
+
+func (i *MaskImage) FrameRate() float64 {
+	return ImageFrameRate(i.Image)
+}
+
+// AnimatedImage plays a sequence of frames. The frame shown is worked out from how long
+// it has been playing, according to its clock, so nothing happens in the background and
+// it doesn't matter how often the frames are drawn.
 type AnimatedImage struct {
-	frameRequest    chan bool
-	pos             int
+	sync.Mutex
 	frames          []*image.RGBA
-	delays          []int
-	remainingLoops  int
-	started         bool
+	delays          []int // in 100ths of a second, as in a gif
+	remainingLoops  int   // the number of times to repeat after the first play, or -1 to loop forever
 	delayAdjustment float64
+
+	durations []time.Duration // how long each frame is shown for
+	total     time.Duration   // the length of one play through all the frames
+
+	clock   Clock
+	started bool
+	start   time.Time // when the animation would have started, if it had never been paused or seeked
+	paused  bool
+	elapsed time.Duration // how far through the animation it was when it was paused
+
+	lastFrame int // the index of the frame last answered by GetNextFrame
 }
 
-func (i *AnimatedImage) GetNextFrame() *image.RGBA {
+// NewAnimatedImage answers an animation showing each frame for its delay (in 100ths of a
+// second), played once and then repeated loops times, or forever if loops is -1.
+func NewAnimatedImage(frames []*image.RGBA, delays []int, loops int) *AnimatedImage {
+	i := &AnimatedImage{
+		frames:          frames,
+		delays:          delays,
+		remainingLoops:  loops,
+		delayAdjustment: 1.0,
+		clock:           DefaultClock,
+	}
+	i.updateDurations()
+	return i
+}
 
-	if !i.started {
-		i.started = true
-		i.start()
+// frameDuration answers how long a frame with the given delay is shown for. Delays under
+// adjustDelayUnder are snapped to a whole number of display frames (at least one), as
+// nobody would notice the difference at longer delays.
+func frameDuration(delay int, adjustment float64) time.Duration {
+	d := time.Duration(float64(delay)*adjustment) * 10 * time.Millisecond
+
+	if d < adjustDelayUnder {
+		// Rounded to nearest int
+		framesToDisplay := int(math.Floor((float64(d) / float64(frameTime)) + 0.5))
+
+		// Show for at least one frame
+		if framesToDisplay == 0 {
+			framesToDisplay = 1
+		}
+
+		d = time.Duration(framesToDisplay) * frameTime
 	}
 
-	frame := i.frames[i.pos]
+	return d
+}
 
-	select {
-	case i.frameRequest <- true:
-	default:
+func (i *AnimatedImage) updateDurations() {
+	i.durations = make([]time.Duration, len(i.frames))
+	i.total = 0
+
+	for f := range i.frames {
+		delay := 0
+		if f < len(i.delays) {
+			delay = i.delays[f]
+		}
+		i.durations[f] = frameDuration(delay, i.delayAdjustment)
+		i.total += i.durations[f]
 	}
+}
 
-	return frame
+// SetClock sets the clock that drives the animation. The animation is reset.
+func (i *AnimatedImage) SetClock(clock Clock) {
+	i.Lock()
+	defer i.Unlock()
+
+	i.clock = clock
+	i.started = false
+	i.elapsed = 0
 }
 
-func (i *AnimatedImage) start() {
+// Frames answers the decoded frames of the animation.
+func (i *AnimatedImage) Frames() []*image.RGBA {
+	return i.frames
+}
+
+// Duration answers how long a single play through all the frames takes.
+func (i *AnimatedImage) Duration() time.Duration {
+	return i.total
+}
+
+// FrameRate answers how many times a second the animation needs to be drawn to show
+// its shortest frame.
+func (i *AnimatedImage) FrameRate() float64 {
+	if len(i.durations) < 2 {
+		return 0
+	}
+
+	shortest := i.durations[0]
+	for _, d := range i.durations {
+		if d < shortest {
+			shortest = d
+		}
+	}
+	return float64(time.Second) / float64(shortest)
+}
+
+// Elapsed answers how long the animation has been playing.
+func (i *AnimatedImage) Elapsed() time.Duration {
+	i.Lock()
+	defer i.Unlock()
+	return i.currentElapsed()
+}
+
+func (i *AnimatedImage) currentElapsed() time.Duration {
+	if i.paused || !i.started {
+		return i.elapsed
+	}
+	return i.clock.Now().Sub(i.start)
+}
+
+// Pause holds the animation on its current frame.
+func (i *AnimatedImage) Pause() {
+	i.Lock()
+	defer i.Unlock()
+
+	if !i.paused {
+		i.elapsed = i.currentElapsed()
+		i.paused = true
+	}
+}
+
+// Resume carries on playing the animation from where it was paused.
+func (i *AnimatedImage) Resume() {
+	i.Lock()
+	defer i.Unlock()
+
+	if i.paused {
+		i.paused = false
+		i.start = i.clock.Now().Add(-i.elapsed)
+	}
+}
+
+// Seek moves the animation to the given time since it started.
+func (i *AnimatedImage) Seek(elapsed time.Duration) {
+	i.Lock()
+	defer i.Unlock()
+
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	i.elapsed = elapsed
+	if i.started {
+		i.start = i.clock.Now().Add(-elapsed)
+	}
+}
+
+// Reset moves the animation back to its first frame, with all of its loops to come. It
+// starts playing again the next time a frame is drawn, unless it is paused.
+func (i *AnimatedImage) Reset() {
+	i.Lock()
+	defer i.Unlock()
+
+	i.started = false
+	i.elapsed = 0
+}
+
+// frameAt answers the index of the frame shown after the animation has been playing for elapsed.
+func (i *AnimatedImage) frameAt(elapsed time.Duration) int {
+	last := len(i.frames) - 1
+
+	if i.total <= 0 || last == 0 {
+		return 0
+	}
 
-	go func() {
-		for {
-			delay := time.Duration(float64(i.delays[i.pos])*i.delayAdjustment) * 10 * time.Millisecond
-
-			if delay < adjustDelayUnder {
-
-				// Rounded to nearest int
-				framesToDisplay := int(math.Floor((float64(delay) / float64(frameTime)) + 0.5))
-
-				// Show for at least one frame
-				if framesToDisplay == 0 {
-					framesToDisplay = 1
-				}
-
-				//log.Printf("Frame wanted a delay of %d so showing for %d frames", delay, framesToDisplay)
-
-				for x := 0; x < framesToDisplay; x++ {
-					// Wait till this frame has been taken
-					<-i.frameRequest
-				}
-
-			} else {
-				// Just sleep. At these amounts noone will notice +-1 frame
-				//log.Printf("Sleeping frame %d for %d", i.pos, delay)
-				time.Sleep(delay)
-			}
-
-			// That was the last frame
-			if i.pos == len(i.frames)-1 {
-
-				if i.remainingLoops == -1 {
-					// Start again, we are looping forever
-					i.pos = 0
-				} else if i.remainingLoops > 0 {
-					// Start again, we still have at least one loop remaining
-					i.pos = 0
-					i.remainingLoops--
-				} else {
-					// We're done, this frame gets shown forever *drops mic*.
-					break
-				}
-			} else {
-				i.pos++
-			}
+	if i.remainingLoops != -1 && elapsed >= i.total*time.Duration(i.remainingLoops+1) {
+		// We're done, this frame gets shown forever *drops mic*.
+		return last
+	}
 
+	elapsed %= i.total
+	for f, d := range i.durations {
+		if elapsed < d {
+			return f
 		}
-	}()
+		elapsed -= d
+	}
+	return last
+}
+
+func (i *AnimatedImage) GetNextFrame() *image.RGBA {
+	i.Lock()
+	defer i.Unlock()
+
+	if !i.started {
+		i.started = true
+		i.start = i.clock.Now().Add(-i.elapsed)
+	}
 
+	i.lastFrame = i.frameAt(i.currentElapsed())
+	return i.frames[i.lastFrame]
+}
+
+// IsDirty answers whether the animation has moved on to another frame since GetNextFrame
+// was last called.
+func (i *AnimatedImage) IsDirty() bool {
+	i.Lock()
+	defer i.Unlock()
+
+	return !i.started || i.frameAt(i.currentElapsed()) != i.lastFrame
 }
 
 // GetPositionFrame returns the frame corresponding to the position given 0....1
@@ -179,76 +365,199 @@ func (i *AnimatedImage) GetPositionFrame(position float64, blend bool) *image.RG
 	return frame
 }
 
+// LoadImage loads an image, answering the missing image placeholder (and logging why)
+// if it can't be. Use OpenImage to handle the error yourself.
 func LoadImage(src string) Image {
+	img, err := OpenImage(src)
+	if err != nil {
+		log.Warningf("Using the missing image placeholder: %s", err)
+		return MissingImage()
+	}
+	return img
+}
+
+// OpenImage loads a gif, png (animated, or a sprite sheet) or jpeg image through the image cache, fitting it to the
+// matrix using the default fit mode.
+func OpenImage(src string) (Image, error) {
+	return OpenImageFit(src, DefaultFitMode())
+}
+
+// LoadImageFit is LoadImage, fitting the image to the matrix using mode.
+func LoadImageFit(src string, mode FitMode) Image {
+	img, err := OpenImageFit(src, mode)
+	if err != nil {
+		log.Warningf("Using the missing image placeholder: %s", err)
+		return MissingImage()
+	}
+	return img
+}
+
+// OpenImageFit is OpenImage, fitting the image to the matrix using mode.
+func OpenImageFit(src string, mode FitMode) (Image, error) {
+	if imageFormat(src) == "" {
+		return nil, fmt.Errorf("Unknown image format: '%s'", src)
+	}
+	return images.load(src, mode)
+}
+
+// imageFormat answers the format of an image file from its name, or "" if it isn't known.
+func imageFormat(src string) string {
 	srcLower := strings.ToLower(src)
 
-	if strings.Contains(srcLower, ".gif") {
-		return LoadGif(src)
+	if spriteWidth(src) > 0 {
+		return "sprite"
+	} else if strings.Contains(srcLower, ".gif") {
+		return "gif"
 	} else if strings.Contains(srcLower, ".png") {
-		return LoadPng(src)
+		return "png"
 	} else if strings.Contains(srcLower, ".jpg") {
-		return LoadJpeg(src)
-	} else {
-		log.HandleError(fmt.Errorf(src), "Unknown image format")
+		return "jpeg"
 	}
-	return nil
+	return ""
 }
 
-func LoadPng(src string) Image {
+func decodeImage(src string, mode FitMode) (Image, error) {
+	switch imageFormat(src) {
+	case "gif":
+		img, err := decodeGif(src, mode)
+		if err != nil {
+			return nil, err
+		}
+		return img, nil
+	case "png":
+		return decodePng(src, mode)
+	case "sprite":
+		img, err := decodeSprite(src, mode)
+		if err != nil {
+			return nil, err
+		}
+		return img, nil
+	case "jpeg":
+		return decodeJpeg(src, mode)
+	}
+	return nil, fmt.Errorf("Unknown image format: '%s'", src)
+}
 
-	file, err := os.Open(src)
+func LoadPng(src string) (Image, error) {
+	return decodePng(src, DefaultFitMode())
+}
+
+func decodePng(src string, mode FitMode) (Image, error) {
+
+	data, err := ioutil.ReadFile(src)
 
 	if err != nil {
-		log.Fatalf("Could not open png '%s' : %s", src, err)
+		return nil, fmt.Errorf("Could not open png '%s' : %s", src, err)
+	}
+
+	if chunks, err := readPngChunks(data); err == nil && isAnimatedPng(chunks) {
+		img, err := decodeAnimatedPng(chunks, mode)
+		if err != nil {
+			return nil, fmt.Errorf("APNG decoding failed on image '%s' : %s", src, err)
+		}
+		return img, nil
 	}
 
-	img, err := png.Decode(file)
+	img, err := png.Decode(bytes.NewReader(data))
 	if err != nil {
-		log.Fatalf("PNG decoding failed on image '%s' : %s", src, err)
+		return nil, fmt.Errorf("PNG decoding failed on image '%s' : %s", src, err)
 	}
 
 	return &SingleImage{
-		frame: toRGBA(img),
-	}
+		frame: FitImage(img, mode),
+	}, nil
 }
 
-func LoadJpeg(src string) Image {
+func LoadJpeg(src string) (Image, error) {
+	return decodeJpeg(src, DefaultFitMode())
+}
+
+func decodeJpeg(src string, mode FitMode) (Image, error) {
 
 	file, err := os.Open(src)
 
 	if err != nil {
-		log.Fatalf("Could not open jpeg '%s' : %s", src, err)
+		return nil, fmt.Errorf("Could not open jpeg '%s' : %s", src, err)
 	}
+	defer file.Close()
 
 	img, err := jpeg.Decode(file)
 	if err != nil {
-		log.Fatalf("JPG decoding failed on image '%s' : %s", src, err)
+		return nil, fmt.Errorf("JPG decoding failed on image '%s' : %s", src, err)
 	}
 
 	return &SingleImage{
-		frame: toRGBA(img),
-	}
+		frame: FitImage(img, mode),
+	}, nil
 }
 
-func LoadGif(src string) *AnimatedImage {
+func LoadGif(src string) (*AnimatedImage, error) {
+	return decodeGif(src, DefaultFitMode())
+}
+
+func decodeGif(src string, mode FitMode) (*AnimatedImage, error) {
 
 	file, err := os.Open(src)
 
 	if err != nil {
-		log.Fatalf("Could not open gif '%s' : %s", src, err)
+		return nil, fmt.Errorf("Could not open gif '%s' : %s", src, err)
+	}
+	defer file.Close()
+
+	img, err := DecodeGif(file, mode)
+	if err != nil {
+		return nil, fmt.Errorf("Gif decoding failed on image '%s' : %s", src, err)
 	}
 
-	img, err := gif.DecodeAll(file)
+	return img, nil
+}
+
+// DecodeGif decodes an animated gif, fitting it to the matrix using mode.
+//
+// Each frame is drawn at its offset on the gif's logical screen, which starts out
+// transparent, and the screen is then fitted to the matrix. Transparent pixels in a
+// frame leave the screen beneath them alone. After a frame has been shown, its disposal
+// method is applied to the area it covered:
+//
+//   - gif.DisposalNone (or unspecified) leaves the frame in place
+//   - gif.DisposalBackground fills it with the background colour
+//   - gif.DisposalPrevious restores what was there before the frame was drawn
+func DecodeGif(r io.Reader, mode FitMode) (*AnimatedImage, error) {
+
+	img, err := gif.DecodeAll(r)
 	if err != nil {
-		log.Fatalf("Gif decoding failed on image '%s' : %s", src, err)
+		return nil, err
+	}
+
+	if len(img.Image) == 0 {
+		return nil, fmt.Errorf("gif has no frames")
 	}
 
 	var frames = []*image.RGBA{}
 
-	last := image.NewRGBA(image.Rect(0, 0, 16, 16))
-	for _, frame := range img.Image {
-		last = palettedToRGBA(last, frame)
-		frames = append(frames, last)
+	background := image.NewUniform(gifBackground(img))
+	screen := image.NewRGBA(gifScreenBounds(img))
+	previous := image.NewRGBA(screen.Rect)
+
+	for i, frame := range img.Image {
+		var disposal byte
+		if i < len(img.Disposal) {
+			disposal = img.Disposal[i]
+		}
+
+		if disposal == gif.DisposalPrevious {
+			copy(previous.Pix, screen.Pix)
+		}
+
+		draw.Draw(screen, frame.Bounds(), frame, frame.Rect.Min, draw.Over)
+		frames = append(frames, FitImage(screen, mode))
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(screen, frame.Bounds(), background, image.ZP, draw.Src)
+		case gif.DisposalPrevious:
+			draw.Draw(screen, frame.Bounds(), previous, frame.Rect.Min, draw.Src)
+		}
 	}
 
 	loops := img.LoopCount
@@ -257,14 +566,17 @@ func LoadGif(src string) *AnimatedImage {
 	} else if loops == -1 {
 		loops = 0
 	}
-	
-	return &AnimatedImage{
-		frames:          frames,
-		delays:          img.Delay,
-		remainingLoops:  loops,
-		frameRequest:    make(chan bool),
-		delayAdjustment: 1.0,
+
+	return NewAnimatedImage(frames, img.Delay, loops), nil
+}
+
+// gifBackground answers the background colour from a gif's global colour table, or
+// transparent if it doesn't have one.
+func gifBackground(img *gif.GIF) color.Color {
+	if palette, ok := img.Config.ColorModel.(color.Palette); ok && int(img.BackgroundIndex) < len(palette) {
+		return palette[img.BackgroundIndex]
 	}
+	return color.Transparent
 }
 
 func toRGBA(in image.Image) *image.RGBA {
@@ -274,13 +586,18 @@ func toRGBA(in image.Image) *image.RGBA {
 	return out
 }
 
-func palettedToRGBA(last *image.RGBA, in *image.Paletted) *image.RGBA {
-	out := image.NewRGBA(image.Rect(0, 0, 16, 16))
-
-	draw.Draw(out, out.Bounds(), last, last.Rect.Min, draw.Src)
-	draw.Draw(out, in.Bounds(), in, in.Rect.Min, draw.Over)
+// gifScreenBounds answers the size of a gif's logical screen, or the area covered by
+// its frames if it doesn't have one.
+func gifScreenBounds(img *gif.GIF) image.Rectangle {
+	if img.Config.Width > 0 && img.Config.Height > 0 {
+		return image.Rect(0, 0, img.Config.Width, img.Config.Height)
+	}
 
-	return out
+	var bounds image.Rectangle
+	for _, frame := range img.Image {
+		bounds = bounds.Union(frame.Bounds())
+	}
+	return image.Rect(0, 0, bounds.Max.X, bounds.Max.Y)
 }
 
 /*
@@ -0,0 +1,181 @@
+package util
+
+import (
+	"image"
+	"testing"
+	"time"
+)
+
+// testAnimation answers an animation of n frames on a manual clock, with each frame
+// shown for delay 100ths of a second.
+func testAnimation(n int, delay int, loops int) (*AnimatedImage, *ManualClock) {
+	frames := make([]*image.RGBA, n)
+	delays := make([]int, n)
+	for f := range frames {
+		frames[f] = image.NewRGBA(image.Rect(0, 0, 16, 16))
+		delays[f] = delay
+	}
+
+	clock := NewManualClock(time.Date(2015, time.June, 1, 10, 4, 0, 0, time.UTC))
+	a := NewAnimatedImage(frames, delays, loops)
+	a.SetClock(clock)
+	return a, clock
+}
+
+// frameIndex answers the index of the frame the animation shows next.
+func frameIndex(t *testing.T, a *AnimatedImage, img Image) int {
+	frame := img.GetNextFrame()
+	for f, candidate := range a.Frames() {
+		if frame == candidate {
+			return f
+		}
+	}
+	t.Fatalf("A frame that isn't in the animation was answered")
+	return -1
+}
+
+func TestFrameDuration(t *testing.T) {
+	tests := []struct {
+		delay    int
+		expected time.Duration
+	}{
+		{0, frameTime},      // shown for at least one frame
+		{1, frameTime},      // 10ms
+		{5, 2 * frameTime},  // 50ms is 1.5 frames, rounded up
+		{10, 3 * frameTime}, // 100ms
+		{29, 9 * frameTime}, // 290ms is 8.7 frames
+		{30, 300 * time.Millisecond},
+		{40, 400 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		if d := frameDuration(test.delay, 1.0); d != test.expected {
+			t.Errorf("A delay of %d should be shown for %s, not %s", test.delay, test.expected, d)
+		}
+	}
+}
+
+func TestAnimationLoops(t *testing.T) {
+	tests := []struct {
+		loops    int
+		elapsed  time.Duration
+		expected int
+	}{
+		{0, 0, 0},
+		{0, 399 * time.Millisecond, 0},
+		{0, 400 * time.Millisecond, 1},
+		{0, 800 * time.Millisecond, 2},
+		{0, 1200 * time.Millisecond, 2}, // played once, so the last frame stays
+		{1, 1200 * time.Millisecond, 0},
+		{1, 2000 * time.Millisecond, 2},
+		{1, 2400 * time.Millisecond, 2},
+		{1, time.Hour, 2},
+		{-1, 2400 * time.Millisecond, 0},
+		{-1, time.Hour + 400*time.Millisecond, 1},
+	}
+
+	for _, test := range tests {
+		a, clock := testAnimation(3, 40, test.loops)
+		frameIndex(t, a, a)
+		clock.Advance(test.elapsed)
+
+		if f := frameIndex(t, a, a); f != test.expected {
+			t.Errorf("With %d loops, after %s frame %d should be shown, not %d", test.loops, test.elapsed, test.expected, f)
+		}
+	}
+}
+
+func TestAnimationPauseAndSeek(t *testing.T) {
+	a, clock := testAnimation(3, 40, -1)
+
+	if !a.IsDirty() {
+		t.Errorf("An animation should be dirty before its first frame")
+	}
+	frameIndex(t, a, a)
+	if a.IsDirty() {
+		t.Errorf("An animation shouldn't be dirty until it moves on")
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	a.Pause()
+	clock.Advance(time.Second)
+	if f := frameIndex(t, a, a); f != 1 || a.Elapsed() != 500*time.Millisecond {
+		t.Errorf("A paused animation should stay at 500ms (frame 1), not %s (frame %d)", a.Elapsed(), f)
+	}
+
+	a.Resume()
+	clock.Advance(300 * time.Millisecond)
+	if f := frameIndex(t, a, a); f != 2 {
+		t.Errorf("A resumed animation should carry on from where it was paused, showing frame 2, not %d", f)
+	}
+
+	a.Seek(0)
+	if !a.IsDirty() {
+		t.Errorf("An animation should be dirty after seeking to another frame")
+	}
+	if f := frameIndex(t, a, a); f != 0 {
+		t.Errorf("Seeking to the start should show frame 0, not %d", f)
+	}
+
+	a.Pause()
+	a.Seek(900 * time.Millisecond)
+	clock.Advance(time.Second)
+	if f := frameIndex(t, a, a); f != 2 {
+		t.Errorf("Seeking while paused should show frame 2, not %d", f)
+	}
+
+	a.Reset()
+	if f := frameIndex(t, a, a); f != 0 {
+		t.Errorf("A reset animation should show frame 0, not %d", f)
+	}
+}
+
+func TestCachedAnimation(t *testing.T) {
+	a, clock := testAnimation(3, 40, -1)
+
+	// Cached under a file that doesn't exist, so it's only reloaded when the test says so
+	key := imageCacheKey{"test/animation.gif", FitArea}
+	cached := &CachedImage{key: key, image: a}
+	var _ Animation = cached
+
+	cached.SetClock(clock)
+	frameIndex(t, a, cached)
+	clock.Advance(500 * time.Millisecond)
+	cached.Pause()
+	clock.Advance(time.Second)
+	if f := frameIndex(t, a, cached); f != 1 {
+		t.Errorf("A paused cached animation should show frame 1, not %d", f)
+	}
+
+	cached.Seek(900 * time.Millisecond)
+	if f := frameIndex(t, a, cached); f != 2 {
+		t.Errorf("Seeking a cached animation should show frame 2, not %d", f)
+	}
+
+	// Reloaded, as if its file had changed
+	images.Lock()
+	images.entries[key] = &imageCacheEntry{version: 1, image: a}
+	images.Unlock()
+	defer func() {
+		images.Lock()
+		delete(images.entries, key)
+		images.Unlock()
+	}()
+
+	reloaded, ok := cached.current().(*AnimatedImage)
+	if !ok || reloaded == a {
+		t.Fatalf("The cached animation should have been reloaded")
+	}
+
+	frameIndex(t, reloaded, cached)
+	clock.Advance(500 * time.Millisecond)
+	if f := frameIndex(t, reloaded, cached); f != 0 {
+		t.Errorf("A reloaded animation should stay paused, showing frame 0, not %d", f)
+	}
+
+	cached.Resume()
+	clock.Advance(500 * time.Millisecond)
+	if f := frameIndex(t, reloaded, cached); f != 1 || reloaded.Elapsed() != 500*time.Millisecond {
+		t.Errorf("A reloaded animation should be played on the same clock, showing frame 1 at 500ms, not frame %d at %s", f, reloaded.Elapsed())
+	}
+}
@@ -0,0 +1,281 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+// Animated PNG support. image/png only decodes the default image of an APNG, so the
+// chunks are split up here and each frame is rebuilt into a standalone png (its
+// fdAT chunks turned back into IDAT chunks) for image/png to decode.
+//
+// See https://wiki.mozilla.org/APNG_Specification
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+const (
+	apngDisposeNone       = 0
+	apngDisposeBackground = 1
+	apngDisposePrevious   = 2
+
+	apngBlendSource = 0
+	apngBlendOver   = 1
+)
+
+type pngChunk struct {
+	kind string
+	data []byte
+}
+
+type apngFrame struct {
+	width, height      int
+	x, y               int
+	delayNum, delayDen uint16
+	dispose, blend     byte
+	data               [][]byte // the image data, from IDAT or fdAT chunks
+}
+
+// readPngChunks splits a png into its chunks.
+func readPngChunks(data []byte) ([]pngChunk, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, fmt.Errorf("not a png")
+	}
+
+	var chunks []pngChunk
+
+	for pos := len(pngSignature); pos < len(data); {
+		if pos+8 > len(data) {
+			return nil, fmt.Errorf("truncated chunk header")
+		}
+
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		kind := string(data[pos+4 : pos+8])
+		start := pos + 8
+		end := start + length
+
+		if length < 0 || end+4 > len(data) || end < start {
+			return nil, fmt.Errorf("truncated %s chunk", kind)
+		}
+
+		chunks = append(chunks, pngChunk{kind, data[start:end]})
+		pos = end + 4 // skip the crc
+
+		if kind == "IEND" {
+			break
+		}
+	}
+
+	return chunks, nil
+}
+
+// isAnimatedPng answers whether the chunks of a png include an animation control chunk.
+func isAnimatedPng(chunks []pngChunk) bool {
+	for _, chunk := range chunks {
+		switch chunk.kind {
+		case "acTL":
+			return true
+		case "IDAT":
+			return false
+		}
+	}
+	return false
+}
+
+// DecodeAPNG decodes an animated png, fitting it to the matrix using mode. A png that
+// isn't animated is decoded as a single frame animation.
+func DecodeAPNG(r io.Reader, mode FitMode) (*AnimatedImage, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, err := readPngChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isAnimatedPng(chunks) {
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return NewAnimatedImage([]*image.RGBA{FitImage(img, mode)}, []int{0}, 0), nil
+	}
+
+	return decodeAnimatedPng(chunks, mode)
+}
+
+func decodeAnimatedPng(chunks []pngChunk, mode FitMode) (*AnimatedImage, error) {
+	var header []byte
+	var shared []pngChunk // chunks needed to decode every frame, e.g. PLTE and tRNS
+	var frames []*apngFrame
+	var current *apngFrame
+	var plays uint32
+	seenData := false
+
+	for _, chunk := range chunks {
+		switch chunk.kind {
+		case "IHDR":
+			if len(chunk.data) != 13 {
+				return nil, fmt.Errorf("bad IHDR chunk")
+			}
+			header = chunk.data
+		case "acTL":
+			if len(chunk.data) != 8 {
+				return nil, fmt.Errorf("bad acTL chunk")
+			}
+			plays = binary.BigEndian.Uint32(chunk.data[4:])
+		case "fcTL":
+			if len(chunk.data) != 26 {
+				return nil, fmt.Errorf("bad fcTL chunk")
+			}
+			d := chunk.data
+			current = &apngFrame{
+				width:    int(binary.BigEndian.Uint32(d[4:])),
+				height:   int(binary.BigEndian.Uint32(d[8:])),
+				x:        int(binary.BigEndian.Uint32(d[12:])),
+				y:        int(binary.BigEndian.Uint32(d[16:])),
+				delayNum: binary.BigEndian.Uint16(d[20:]),
+				delayDen: binary.BigEndian.Uint16(d[22:]),
+				dispose:  d[24],
+				blend:    d[25],
+			}
+			frames = append(frames, current)
+		case "IDAT":
+			seenData = true
+			// The default image is only part of the animation if an fcTL came before it
+			if current != nil {
+				current.data = append(current.data, chunk.data)
+			}
+		case "fdAT":
+			seenData = true
+			if current == nil || len(chunk.data) < 4 {
+				return nil, fmt.Errorf("fdAT chunk without a frame")
+			}
+			current.data = append(current.data, chunk.data[4:])
+		case "IEND":
+		default:
+			if !seenData {
+				shared = append(shared, chunk)
+			}
+		}
+	}
+
+	if header == nil {
+		return nil, fmt.Errorf("missing IHDR chunk")
+	}
+
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("animated png has no frames")
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, int(binary.BigEndian.Uint32(header[0:])), int(binary.BigEndian.Uint32(header[4:]))))
+	previous := image.NewRGBA(canvas.Rect)
+
+	var images []*image.RGBA
+	var delays []int
+
+	for i, frame := range frames {
+		bounds := image.Rect(frame.x, frame.y, frame.x+frame.width, frame.y+frame.height)
+		if bounds.Empty() || !bounds.In(canvas.Rect) {
+			return nil, fmt.Errorf("frame %d is outside the image", i)
+		}
+
+		img, err := frame.decode(header, shared)
+		if err != nil {
+			return nil, fmt.Errorf("frame %d: %s", i, err)
+		}
+
+		dispose := frame.dispose
+		if i == 0 && dispose == apngDisposePrevious {
+			dispose = apngDisposeBackground
+		}
+
+		if dispose == apngDisposePrevious {
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		op := draw.Over
+		if frame.blend == apngBlendSource {
+			op = draw.Src
+		}
+
+		draw.Draw(canvas, bounds, img, img.Bounds().Min, op)
+		images = append(images, FitImage(canvas, mode))
+		delays = append(delays, frame.delay())
+
+		switch dispose {
+		case apngDisposeBackground:
+			draw.Draw(canvas, bounds, image.Transparent, image.ZP, draw.Src)
+		case apngDisposePrevious:
+			draw.Draw(canvas, bounds, previous, bounds.Min, draw.Src)
+		}
+	}
+
+	// Same as a gif. -1 loops forever, otherwise the number of repeats after the first play.
+	loops := -1
+	if plays > 0 {
+		loops = int(plays) - 1
+	}
+
+	return NewAnimatedImage(images, delays, loops), nil
+}
+
+// delay answers the frame's delay in 100ths of a second, as used by gifs.
+func (f *apngFrame) delay() int {
+	den := float64(f.delayDen)
+	if den == 0 {
+		den = 100
+	}
+	return int(math.Floor(float64(f.delayNum)*100/den + 0.5))
+}
+
+// decode rebuilds the frame as a standalone png and decodes it.
+func (f *apngFrame) decode(header []byte, shared []pngChunk) (image.Image, error) {
+	if len(f.data) == 0 {
+		return nil, fmt.Errorf("no image data")
+	}
+
+	var buf bytes.Buffer
+	buf.Write(pngSignature)
+
+	frameHeader := append([]byte(nil), header...)
+	binary.BigEndian.PutUint32(frameHeader[0:], uint32(f.width))
+	binary.BigEndian.PutUint32(frameHeader[4:], uint32(f.height))
+	writePngChunk(&buf, "IHDR", frameHeader)
+
+	for _, chunk := range shared {
+		writePngChunk(&buf, chunk.kind, chunk.data)
+	}
+
+	for _, data := range f.data {
+		writePngChunk(&buf, "IDAT", data)
+	}
+
+	writePngChunk(&buf, "IEND", nil)
+
+	return png.Decode(&buf)
+}
+
+func writePngChunk(buf *bytes.Buffer, kind string, data []byte) {
+	var word [4]byte
+
+	binary.BigEndian.PutUint32(word[:], uint32(len(data)))
+	buf.Write(word[:])
+	buf.WriteString(kind)
+	buf.Write(data)
+
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(kind))
+	crc.Write(data)
+	binary.BigEndian.PutUint32(word[:], crc.Sum32())
+	buf.Write(word[:])
+}
@@ -0,0 +1,26 @@
+package util
+
+import (
+	"image"
+)
+
+// ScaleBrightness answers a copy of frame with every colour scaled by scale (0...1).
+// The original frame is left alone, as panes often hand back images they reuse.
+func ScaleBrightness(frame *image.RGBA, scale float64) *image.RGBA {
+	if scale < 0 {
+		scale = 0
+	} else if scale > 1 {
+		scale = 1
+	}
+
+	out := image.NewRGBA(frame.Bounds())
+	copy(out.Pix, frame.Pix)
+
+	for i := 0; i+3 < len(out.Pix); i = i + 4 {
+		out.Pix[i] = uint8(float64(out.Pix[i]) * scale)
+		out.Pix[i+1] = uint8(float64(out.Pix[i+1]) * scale)
+		out.Pix[i+2] = uint8(float64(out.Pix[i+2]) * scale)
+	}
+
+	return out
+}
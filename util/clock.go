@@ -0,0 +1,83 @@
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells animations what the time is, so they can be driven by something other
+// than the wall clock (e.g. a fake clock, to step through frames one at a time).
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock is the wall clock.
+var SystemClock Clock = systemClock{}
+
+// DefaultClock is used by new animations unless they are given another, and by the
+// panes. It tells the time of the wall clock, unless something like the pane harness
+// has switched it to another clock with SetDefaultClock.
+var DefaultClock Clock = defaultClock
+
+var defaultClock = &switchableClock{clock: SystemClock}
+
+// switchableClock tells the time of another clock, which can be changed while it's in use.
+type switchableClock struct {
+	sync.RWMutex
+	clock Clock
+}
+
+func (c *switchableClock) Now() time.Time {
+	c.RLock()
+	defer c.RUnlock()
+	return c.clock.Now()
+}
+
+// SetDefaultClock switches DefaultClock, and the animations using it, to the time of
+// clock, or back to the wall clock if clock is nil.
+func SetDefaultClock(clock Clock) {
+	if clock == nil {
+		clock = SystemClock
+	}
+
+	defaultClock.Lock()
+	defer defaultClock.Unlock()
+	defaultClock.clock = clock
+}
+
+// ManualClock is a Clock that only moves when it is told to.
+type ManualClock struct {
+	sync.Mutex
+	now time.Time
+}
+
+// NewManualClock answers a clock stopped at now.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set moves the clock to now.
+func (c *ManualClock) Set(now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	c.now = now
+}
@@ -0,0 +1,312 @@
+package util
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How long we wait for the matrix to acknowledge a frame, or answer a query
+const ackTimeout = time.Second * 3
+const queryTimeout = time.Second
+
+// MatrixInfo describes a led matrix and the driver used to talk to it.
+type MatrixInfo struct {
+	Driver string `json:"driver"`
+	Device string `json:"device"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+
+	// The version reported by the matrix firmware, if it supports the query
+	Firmware string `json:"firmware,omitempty"`
+
+	// The number of frames written that the matrix didn't acknowledge, or answered
+	// with something other than an 'F'. They're still shown, so aren't failed writes.
+	FailedAcks int `json:"failedAcks"`
+}
+
+// Matrix is a driver for something that can display our 16x16 frames.
+type Matrix interface {
+	Open() error
+	WriteFrame(frame *image.RGBA) error
+	Close() error
+	Info() MatrixInfo
+}
+
+var matrixDrivers = map[string]func() Matrix{
+	"serial": func() Matrix {
+		return newStreamMatrix("serial", ledDevice(), GetLEDConnection)
+	},
+	"mock": func() Matrix {
+		return newStreamMatrix("mock", "mock", func() (io.ReadWriteCloser, error) {
+			return newMockMatrix(), nil
+		})
+	},
+	"emulator": func() Matrix {
+		return newStreamMatrix("emulator", emulatorSink+":"+emulatorPath, newEmulatorConnection)
+	},
+	"network": func() Matrix {
+		return newNetworkMatrix(networkProtocol, networkAddress)
+	},
+}
+
+// FirmwareMatrix is implemented by matrices whose firmware can be queried for its
+// version and the temperature of the board.
+type FirmwareMatrix interface {
+	Matrix
+	Version() (string, error)
+	Temperature() (*Temperature, error)
+}
+
+// Temperature is a reading from the matrix's temperature sensor. The values are the
+// 10 bit ADC readings reported by the firmware, which fall as the board heats up.
+type Temperature struct {
+	Value   int       `json:"value"` // filtered, as used by the firmware for its own dimming
+	Raw     int       `json:"raw"`
+	Celsius float64   `json:"celsius"`
+	Time    time.Time `json:"time"`
+}
+
+// The conversion used by the matrix firmware to display its temperature
+func adcToCelsius(adc int) float64 {
+	return 199.1700229 - 0.3031661571*float64(adc)
+}
+
+// RegisterMatrixDriver makes a matrix driver available to NewMatrix.
+func RegisterMatrixDriver(name string, factory func() Matrix) {
+	matrixDrivers[name] = factory
+}
+
+// MatrixDrivers answers the names of the registered matrix drivers.
+func MatrixDrivers() []string {
+	names := make([]string, 0, len(matrixDrivers))
+	for name := range matrixDrivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewMatrix answers an unopened matrix using the named driver.
+func NewMatrix(driver string) (Matrix, error) {
+	factory, ok := matrixDrivers[driver]
+	if !ok {
+		return nil, fmt.Errorf("Unknown LED matrix driver '%s'. Available: %v", driver, MatrixDrivers())
+	}
+	return factory(), nil
+}
+
+// streamMatrix speaks the matrix firmware's serial protocol over a stream. It is used
+// for the real AVR serial link, as well as the mock and emulated matrices.
+//
+// Everything the matrix sends back is read by a single goroutine, so frame writes
+// and firmware queries can be interleaved without either blocking the other forever.
+type streamMatrix struct {
+	sync.Mutex
+	info     MatrixInfo
+	connect  func() (io.ReadWriteCloser, error)
+	stream   io.ReadWriteCloser
+	incoming chan byte
+}
+
+func newStreamMatrix(driver string, device string, connect func() (io.ReadWriteCloser, error)) *streamMatrix {
+	return &streamMatrix{
+		info: MatrixInfo{
+			Driver: driver,
+			Device: device,
+			Width:  16,
+			Height: 16,
+		},
+		connect: connect,
+	}
+}
+
+func (m *streamMatrix) Open() error {
+	m.Lock()
+	defer m.Unlock()
+
+	s, err := m.connect()
+	if err != nil {
+		return err
+	}
+	m.stream = s
+	m.incoming = make(chan byte, 256)
+
+	go m.read(s, m.incoming)
+
+	if version, err := m.query(cmdReadVersion, queryTimeout); err != nil {
+		log.Warningf("LED matrix firmware did not report its version: %s", err)
+	} else {
+		m.info.Firmware = strings.TrimPrefix(version, "V")
+		log.Infof("LED matrix firmware version: %s", m.info.Firmware)
+	}
+
+	return nil
+}
+
+func (m *streamMatrix) read(s io.Reader, incoming chan byte) {
+	defer close(incoming)
+
+	buf := make([]byte, 64)
+	for {
+		n, err := s.Read(buf)
+		for _, b := range buf[:n] {
+			incoming <- b
+		}
+		if err != nil {
+			log.Debugf("Stopped reading from LED matrix: %s", err)
+			return
+		}
+	}
+}
+
+// Throws away anything the matrix sent that we weren't waiting for.
+func (m *streamMatrix) drain() {
+	for {
+		select {
+		case <-m.incoming:
+		default:
+			return
+		}
+	}
+}
+
+func (m *streamMatrix) readByte(timeout time.Duration) (byte, error) {
+	select {
+	case b, ok := <-m.incoming:
+		if !ok {
+			return 0, fmt.Errorf("connection to LED matrix was closed")
+		}
+		return b, nil
+	case <-time.After(timeout):
+		return 0, fmt.Errorf("timed out after %s waiting for LED matrix", timeout)
+	}
+}
+
+// Sends a single byte query, answering the line sent back (without the line ending).
+// Must be called with the lock held.
+func (m *streamMatrix) query(cmd byte, timeout time.Duration) (string, error) {
+	if m.stream == nil {
+		return "", fmt.Errorf("LED matrix is not open")
+	}
+
+	m.drain()
+
+	if _, err := m.stream.Write([]byte{cmd}); err != nil {
+		return "", fmt.Errorf("Failed writing query '%c': %s", cmd, err)
+	}
+
+	var line []byte
+	for len(line) < 32 {
+		b, err := m.readByte(timeout)
+		if err != nil {
+			return "", err
+		}
+		if b == '\n' {
+			return strings.TrimSpace(string(line)), nil
+		}
+		line = append(line, b)
+	}
+
+	return "", fmt.Errorf("reply to query '%c' was too long: %q", cmd, line)
+}
+
+func (m *streamMatrix) queryInt(cmd byte) (int, error) {
+	reply, err := m.query(cmd, queryTimeout)
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := strconv.ParseInt(reply, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("Bad reply to query '%c': %q", cmd, reply)
+	}
+	return int(value), nil
+}
+
+func (m *streamMatrix) WriteFrame(frame *image.RGBA) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.stream == nil {
+		return fmt.Errorf("LED matrix is not open")
+	}
+
+	m.drain()
+
+	if err := sendFrame(m.stream, frame); err != nil {
+		return err
+	}
+
+	// The frame has been sent, so a missing or unexpected acknowledgement is only logged
+	// (and counted in the info), as it always has been. It doesn't count as a failed write.
+	b, err := m.readByte(ackTimeout)
+	if err != nil {
+		m.info.FailedAcks++
+		log.Infof("Failed to read char after sending frame : %s", err)
+	} else if b != byte('F') {
+		m.info.FailedAcks++
+		log.Infof("Expected an 'F', got '%q'", b)
+	}
+
+	return nil
+}
+
+// Version answers the firmware version of the matrix.
+func (m *streamMatrix) Version() (string, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	version, err := m.query(cmdReadVersion, queryTimeout)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(version, "V"), nil
+}
+
+// Temperature samples the matrix's temperature sensor.
+func (m *streamMatrix) Temperature() (*Temperature, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	value, err := m.queryInt(cmdReadTemp)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := m.queryInt(cmdReadRawTemp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Temperature{
+		Value:   value,
+		Raw:     raw,
+		Celsius: adcToCelsius(value),
+		Time:    time.Now(),
+	}, nil
+}
+
+func (m *streamMatrix) Close() error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.stream == nil {
+		return nil
+	}
+
+	err := m.stream.Close()
+	m.stream = nil
+	return err
+}
+
+func (m *streamMatrix) Info() MatrixInfo {
+	m.Lock()
+	defer m.Unlock()
+	return m.info
+}
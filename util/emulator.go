@@ -0,0 +1,163 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"sync"
+
+	"github.com/ninjasphere/go-ninja/config"
+)
+
+var emulatorSink = config.String("png", "led.emulator.sink")
+var emulatorPath = config.String("led-matrix.png", "led.emulator.path")
+
+// FrameSink receives the frames decoded by the led matrix emulator.
+type FrameSink interface {
+	WriteFrame(frame *image.RGBA) error
+	Close() error
+}
+
+// Emulator is a software led matrix. It speaks the same serial protocol as the
+// matrix firmware, decoding each swapped buffer back into an image and handing
+// it to a FrameSink.
+type Emulator struct {
+	sync.Mutex
+	sink    FrameSink
+	state   int    // the current state of the connection
+	buffer  []byte // the back buffer being written
+	replies bytes.Buffer
+	ready   *sync.Cond
+	frames  int
+
+	temperature int // the emulated ADC temperature reading
+}
+
+// The firmware version reported by the emulator
+const emulatorVersion = "emulator"
+
+// NewEmulator answers an emulated led matrix connection that writes its frames to sink.
+func NewEmulator(sink FrameSink) *Emulator {
+	e := &Emulator{
+		sink:        sink,
+		state:       stateCmd,
+		buffer:      make([]byte, 0, frameSize),
+		temperature: mockTemperature,
+	}
+	e.ready = sync.NewCond(&e.Mutex)
+	return e
+}
+
+// Frames answers the number of frames that have been swapped onto the emulated display.
+func (e *Emulator) Frames() int {
+	e.Lock()
+	defer e.Unlock()
+	return e.frames
+}
+
+// SetTemperature sets the ADC value reported when the temperature is read.
+func (e *Emulator) SetTemperature(adc int) {
+	e.Lock()
+	defer e.Unlock()
+	e.temperature = adc
+}
+
+// Write decodes the command and data bytes sent to the matrix.
+func (e *Emulator) Write(p []byte) (n int, err error) {
+	e.Lock()
+	defer e.Unlock()
+
+	for i := 0; i < len(p); i++ {
+		switch e.state {
+		case stateClose:
+			return i, fmt.Errorf("stream is closed")
+		case stateData:
+			count := frameSize - len(e.buffer)
+			if count > len(p)-i {
+				count = len(p) - i
+			}
+			e.buffer = append(e.buffer, p[i:i+count]...)
+			i += count - 1
+
+			if len(e.buffer) == frameSize {
+				e.state = stateCmd
+			}
+		default:
+			switch p[i] {
+			case cmdWriteBuffer:
+				e.buffer = e.buffer[:0]
+				e.state = stateData
+			case cmdSwapBuffers:
+				if err := e.swap(); err != nil {
+					return i + 1, err
+				}
+			case cmdReadTemp, cmdReadRawTemp:
+				e.reply(fmt.Sprintf("0x%03x\r\n", e.temperature))
+			case cmdReadVersion:
+				e.reply("V" + emulatorVersion + "\r\n")
+			default:
+				log.Debugf("Emulator ignoring unknown command: 0x%02x", p[i])
+			}
+		}
+	}
+
+	return len(p), nil
+}
+
+func (e *Emulator) swap() error {
+	e.frames++
+
+	if len(e.buffer) == frameSize {
+		if err := e.sink.WriteFrame(UnconvertImage(e.buffer)); err != nil {
+			return err
+		}
+	}
+
+	e.reply("F")
+	return nil
+}
+
+func (e *Emulator) reply(s string) {
+	e.replies.WriteString(s)
+	e.ready.Broadcast()
+}
+
+// Read answers any replies from the emulated firmware, blocking until there is one.
+func (e *Emulator) Read(p []byte) (n int, err error) {
+	e.Lock()
+	defer e.Unlock()
+
+	for e.replies.Len() == 0 {
+		if e.state == stateClose {
+			return 0, fmt.Errorf("stream is closed")
+		}
+		e.ready.Wait()
+	}
+
+	return e.replies.Read(p)
+}
+
+// Close closes the emulated connection and its sink.
+func (e *Emulator) Close() error {
+	e.Lock()
+	defer e.Unlock()
+
+	if e.state == stateClose {
+		return nil
+	}
+
+	e.state = stateClose
+	e.ready.Broadcast()
+	return e.sink.Close()
+}
+
+// newEmulatorConnection answers an emulator writing to the configured sink.
+func newEmulatorConnection() (io.ReadWriteCloser, error) {
+	sink, err := NewFrameSink(emulatorSink, emulatorPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewEmulator(sink), nil
+}
@@ -0,0 +1,157 @@
+package util
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/ninjasphere/go-ninja/config"
+)
+
+// FitMode is how an image that isn't 16x16 is fitted to the matrix.
+type FitMode string
+
+const (
+	// FitNearest stretches the image to 16x16, sampling the nearest pixel. Best for pixel art.
+	FitNearest FitMode = "nearest"
+	// FitArea stretches the image to 16x16, averaging the pixels each led covers.
+	FitArea FitMode = "area"
+	// FitCrop scales the shorter side to 16, averaging, and crops the centre of the longer side.
+	FitCrop FitMode = "crop"
+	// FitLetterbox scales the longer side to 16, averaging, and centres it with transparent borders.
+	FitLetterbox FitMode = "letterbox"
+)
+
+var defaultFitMode = config.String(string(FitArea), "led.images.fit")
+
+// The size of the matrix that images are fitted to
+var matrixBounds = image.Rect(0, 0, 16, 16)
+
+// ParseFitMode answers the FitMode with the given name.
+func ParseFitMode(name string) (FitMode, error) {
+	switch mode := FitMode(name); mode {
+	case FitNearest, FitArea, FitCrop, FitLetterbox:
+		return mode, nil
+	}
+	return "", fmt.Errorf("Unknown fit mode: '%s'", name)
+}
+
+// SetDefaultFitMode replaces the fit mode set by led.images.fit, for the images opened
+// afterwards.
+func SetDefaultFitMode(mode FitMode) {
+	defaultFitMode = string(mode)
+}
+
+// DefaultFitMode answers the fit mode set by led.images.fit.
+func DefaultFitMode() FitMode {
+	mode, err := ParseFitMode(defaultFitMode)
+	if err != nil {
+		log.Warningf("%s. Using %s.", err, FitArea)
+		return FitArea
+	}
+	return mode
+}
+
+// FitImage answers a 16x16 copy of in, fitted using mode. A 16x16 image is copied as is.
+func FitImage(in image.Image, mode FitMode) *image.RGBA {
+	src := toRGBA(in)
+	out := image.NewRGBA(matrixBounds)
+
+	w, h := float64(src.Rect.Dx()), float64(src.Rect.Dy())
+	if w == 0 || h == 0 {
+		return out
+	}
+
+	if src.Rect.Eq(matrixBounds) {
+		copy(out.Pix, src.Pix)
+		return out
+	}
+
+	size := float64(matrixBounds.Dx())
+
+	switch mode {
+	case FitNearest:
+		resampleNearest(out, out.Rect, src)
+	case FitCrop:
+		// The centred square of src, filling the matrix
+		side := math.Min(w, h)
+		resampleArea(out, out.Rect, src, (w-side)/2, (h-side)/2, side, side)
+	case FitLetterbox:
+		// All of src, with its longer side filling the matrix
+		scale := size / math.Max(w, h)
+		dw, dh := int(math.Floor(w*scale+0.5)), int(math.Floor(h*scale+0.5))
+		if dw == 0 {
+			dw = 1
+		}
+		if dh == 0 {
+			dh = 1
+		}
+		x, y := (matrixBounds.Dx()-dw)/2, (matrixBounds.Dy()-dh)/2
+		resampleArea(out, image.Rect(x, y, x+dw, y+dh), src, 0, 0, w, h)
+	default:
+		resampleArea(out, out.Rect, src, 0, 0, w, h)
+	}
+
+	return out
+}
+
+// resampleNearest stretches all of src over dr in dst, taking the pixel nearest the
+// centre of each destination pixel.
+func resampleNearest(dst *image.RGBA, dr image.Rectangle, src *image.RGBA) {
+	for y := 0; y < dr.Dy(); y++ {
+		sy := (y*2 + 1) * src.Rect.Dy() / (dr.Dy() * 2)
+		for x := 0; x < dr.Dx(); x++ {
+			sx := (x*2 + 1) * src.Rect.Dx() / (dr.Dx() * 2)
+
+			s := src.PixOffset(src.Rect.Min.X+sx, src.Rect.Min.Y+sy)
+			d := dst.PixOffset(dr.Min.X+x, dr.Min.Y+y)
+			copy(dst.Pix[d:d+4], src.Pix[s:s+4])
+		}
+	}
+}
+
+// resampleArea stretches the region (sx, sy, sw, sh) of src over dr in dst. Each
+// destination pixel is the average of the source pixels it covers, weighted by how
+// much of each is covered.
+func resampleArea(dst *image.RGBA, dr image.Rectangle, src *image.RGBA, sx, sy, sw, sh float64) {
+	scaleX, scaleY := sw/float64(dr.Dx()), sh/float64(dr.Dy())
+
+	for y := 0; y < dr.Dy(); y++ {
+		y0 := sy + float64(y)*scaleY
+		y1 := y0 + scaleY
+
+		for x := 0; x < dr.Dx(); x++ {
+			x0 := sx + float64(x)*scaleX
+			x1 := x0 + scaleX
+
+			var sum [4]float64
+			var total float64
+
+			for py := int(math.Floor(y0)); float64(py) < y1 && py < src.Rect.Dy(); py++ {
+				wy := math.Min(y1, float64(py+1)) - math.Max(y0, float64(py))
+
+				for px := int(math.Floor(x0)); float64(px) < x1 && px < src.Rect.Dx(); px++ {
+					weight := wy * (math.Min(x1, float64(px+1)) - math.Max(x0, float64(px)))
+					if weight <= 0 {
+						continue
+					}
+
+					s := src.PixOffset(src.Rect.Min.X+px, src.Rect.Min.Y+py)
+					for c := 0; c < 4; c++ {
+						sum[c] += float64(src.Pix[s+c]) * weight
+					}
+					total += weight
+				}
+			}
+
+			if total == 0 {
+				continue
+			}
+
+			d := dst.PixOffset(dr.Min.X+x, dr.Min.Y+y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[d+c] = uint8(math.Min(255, math.Floor(sum[c]/total+0.5)))
+			}
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package util
+
+import (
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"os"
+	"sync"
+	"time"
+)
+
+// NewFrameSink answers a sink of the named kind ("png", "gif" or "memory"). For the
+// file based sinks path is the file written to, for the memory sink it is ignored.
+func NewFrameSink(kind string, path string) (FrameSink, error) {
+	switch kind {
+	case "png":
+		return NewPNGSink(path), nil
+	case "gif":
+		return NewGIFSink(path, 300), nil
+	case "memory":
+		return NewRingSink(300), nil
+	}
+	return nil, fmt.Errorf("Unknown frame sink: %s", kind)
+}
+
+func copyFrame(frame *image.RGBA) *image.RGBA {
+	out := image.NewRGBA(frame.Bounds())
+	copy(out.Pix, frame.Pix)
+	return out
+}
+
+// writeFileAtomic writes a file via a temporary file, so readers never see a partial image.
+func writeFileAtomic(path string, write func(f *os.File) error) error {
+	tmp := path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// PNGSink keeps a PNG snapshot of the most recent frame on disk.
+type PNGSink struct {
+	path string
+	last []uint8
+}
+
+func NewPNGSink(path string) *PNGSink {
+	return &PNGSink{path: path}
+}
+
+func (s *PNGSink) WriteFrame(frame *image.RGBA) error {
+	if s.last != nil && string(s.last) == string(frame.Pix) {
+		// Nothing has changed, don't bother touching the disk.
+		return nil
+	}
+	s.last = append(s.last[:0], frame.Pix...)
+
+	return writeFileAtomic(s.path, func(f *os.File) error {
+		return png.Encode(f, frame)
+	})
+}
+
+func (s *PNGSink) Close() error {
+	return nil
+}
+
+// GIFSink records the most recent frames as an animated GIF, keeping the real time
+// between frames. The file is rewritten at most once a second, and on Close.
+type GIFSink struct {
+	sync.Mutex
+	path      string
+	maxFrames int
+	frames    []*image.Paletted
+	delays    []int
+	lastFrame time.Time
+	lastWrite time.Time
+}
+
+func NewGIFSink(path string, maxFrames int) *GIFSink {
+	return &GIFSink{
+		path:      path,
+		maxFrames: maxFrames,
+	}
+}
+
+func (s *GIFSink) WriteFrame(frame *image.RGBA) error {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+
+	if len(s.frames) > 0 {
+		// GIF delays are in 100ths of a second
+		s.delays[len(s.delays)-1] = int(now.Sub(s.lastFrame) / (10 * time.Millisecond))
+	}
+	s.lastFrame = now
+
+	paletted := image.NewPaletted(frame.Bounds(), palette.Plan9)
+	draw.Draw(paletted, paletted.Bounds(), frame, frame.Bounds().Min, draw.Src)
+
+	s.frames = append(s.frames, paletted)
+	s.delays = append(s.delays, 0)
+
+	if len(s.frames) > s.maxFrames {
+		s.frames = s.frames[1:]
+		s.delays = s.delays[1:]
+	}
+
+	if now.Sub(s.lastWrite) > time.Second {
+		s.lastWrite = now
+		return s.write()
+	}
+
+	return nil
+}
+
+func (s *GIFSink) write() error {
+	if len(s.frames) == 0 {
+		return nil
+	}
+
+	return writeFileAtomic(s.path, func(f *os.File) error {
+		return gif.EncodeAll(f, &gif.GIF{
+			Image: s.frames,
+			Delay: s.delays,
+		})
+	})
+}
+
+func (s *GIFSink) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	return s.write()
+}
+
+// RingSink keeps the most recent frames in memory, so they can be inspected.
+type RingSink struct {
+	sync.Mutex
+	frames []*image.RGBA
+	next   int
+	count  int
+}
+
+func NewRingSink(size int) *RingSink {
+	return &RingSink{
+		frames: make([]*image.RGBA, size),
+	}
+}
+
+func (s *RingSink) WriteFrame(frame *image.RGBA) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.frames[s.next] = copyFrame(frame)
+	s.next = (s.next + 1) % len(s.frames)
+	if s.count < len(s.frames) {
+		s.count++
+	}
+	return nil
+}
+
+// Frames answers the frames currently held, oldest first.
+func (s *RingSink) Frames() []*image.RGBA {
+	s.Lock()
+	defer s.Unlock()
+
+	frames := make([]*image.RGBA, 0, s.count)
+	for i := 0; i < s.count; i++ {
+		frames = append(frames, s.frames[(s.next-s.count+i+len(s.frames))%len(s.frames)])
+	}
+	return frames
+}
+
+// Last answers the most recent frame, or nil if none have been received.
+func (s *RingSink) Last() *image.RGBA {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.count == 0 {
+		return nil
+	}
+	return s.frames[(s.next-1+len(s.frames))%len(s.frames)]
+}
+
+func (s *RingSink) Close() error {
+	return nil
+}
@@ -0,0 +1,205 @@
+package util
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Each golden image in testdata/gifs is a strip of the 16x16 frames of the gif in
+// images/ with the same name. Rewrite them with 'go test ./util -update', after an
+// intended change.
+var update = flag.Bool("update", false, "rewrite the golden frames")
+
+func TestGifGoldens(t *testing.T) {
+	files, err := filepath.Glob("../images/*.gif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("There are no gifs to check")
+	}
+
+	for _, file := range files {
+		golden := filepath.Join("testdata/gifs", strings.TrimSuffix(filepath.Base(file), ".gif")+".png")
+		if err := checkGolden(file, golden); err != nil {
+			t.Errorf("%s: %s", file, err)
+		}
+	}
+}
+
+func checkGolden(file, golden string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	img, err := DecodeGif(f, FitArea)
+	if err != nil {
+		return err
+	}
+
+	strip := frameStrip(img.Frames())
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+			return err
+		}
+		out, err := os.Create(golden)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		return png.Encode(out, strip)
+	}
+
+	in, err := os.Open(golden)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	expected, err := png.Decode(in)
+	if err != nil {
+		return err
+	}
+
+	expectedRGBA := image.NewRGBA(expected.Bounds())
+	draw.Draw(expectedRGBA, expectedRGBA.Rect, expected, expected.Bounds().Min, draw.Src)
+
+	if !expectedRGBA.Rect.Eq(strip.Rect) {
+		return fmt.Errorf("expected %d frames, got %d", expectedRGBA.Rect.Dx()/16, len(img.Frames()))
+	}
+
+	for i := range img.Frames() {
+		frame := expectedRGBA.SubImage(image.Rect(i*16, 0, i*16+16, 16)).(*image.RGBA)
+		if !sameFrame(frame, img.Frames()[i]) {
+			return fmt.Errorf("frame %d is different", i)
+		}
+	}
+
+	return nil
+}
+
+func frameStrip(frames []*image.RGBA) *image.RGBA {
+	strip := image.NewRGBA(image.Rect(0, 0, 16*len(frames), 16))
+	for i, frame := range frames {
+		draw.Draw(strip, image.Rect(i*16, 0, i*16+16, 16), frame, image.ZP, draw.Src)
+	}
+	return strip
+}
+
+func sameFrame(a, b *image.RGBA) bool {
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			if a.RGBAAt(a.Rect.Min.X+x, a.Rect.Min.Y+y) != b.RGBAAt(b.Rect.Min.X+x, b.Rect.Min.Y+y) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+var (
+	transparent = color.RGBA{}
+	red         = color.RGBA{255, 0, 0, 255}
+	green       = color.RGBA{0, 255, 0, 255}
+	blue        = color.RGBA{0, 0, 255, 255}
+	testPalette = color.Palette{transparent, red, green, blue}
+)
+
+// paletted answers a frame at r, filled with c.
+func paletted(r image.Rectangle, c color.Color) *image.Paletted {
+	frame := image.NewPaletted(r, testPalette)
+	draw.Draw(frame, r, image.NewUniform(c), image.ZP, draw.Src)
+	return frame
+}
+
+type pixel struct {
+	frame, x, y int
+	expected    color.RGBA
+}
+
+// TestGifDisposal decodes small gifs built to exercise each disposal method and transparency.
+func TestGifDisposal(t *testing.T) {
+	screen := image.Rect(0, 0, 16, 16)
+
+	// A frame with a single green pixel at (8, 8), transparent everywhere else
+	dot := paletted(screen, transparent)
+	dot.Set(8, 8, green)
+
+	tests := []struct {
+		name   string
+		gif    *gif.GIF
+		pixels []pixel
+	}{
+		{
+			name: "transparency",
+			gif: &gif.GIF{
+				Image:    []*image.Paletted{paletted(screen, red), dot},
+				Delay:    []int{10, 10},
+				Disposal: []byte{gif.DisposalNone, gif.DisposalNone},
+			},
+			pixels: []pixel{{1, 0, 0, red}, {1, 8, 8, green}},
+		},
+		{
+			name: "background",
+			gif: &gif.GIF{
+				Image:           []*image.Paletted{paletted(screen, red), paletted(image.Rect(4, 4, 8, 8), green), dot},
+				Delay:           []int{10, 10, 10},
+				Disposal:        []byte{gif.DisposalNone, gif.DisposalBackground, gif.DisposalNone},
+				BackgroundIndex: 3,
+			},
+			pixels: []pixel{{1, 0, 0, red}, {1, 5, 5, green}, {2, 5, 5, blue}, {2, 0, 0, red}, {2, 8, 8, green}},
+		},
+		{
+			name: "previous",
+			gif: &gif.GIF{
+				Image:    []*image.Paletted{paletted(screen, red), paletted(image.Rect(0, 0, 4, 4), green), paletted(image.Rect(15, 15, 16, 16), blue)},
+				Delay:    []int{10, 10, 10},
+				Disposal: []byte{gif.DisposalNone, gif.DisposalPrevious, gif.DisposalNone},
+			},
+			pixels: []pixel{{1, 0, 0, green}, {2, 0, 0, red}, {2, 15, 15, blue}},
+		},
+		{
+			name: "offsets",
+			gif: &gif.GIF{
+				Image:    []*image.Paletted{paletted(image.Rect(12, 12, 16, 16), blue)},
+				Delay:    []int{10},
+				Disposal: []byte{gif.DisposalNone},
+			},
+			pixels: []pixel{{0, 0, 0, transparent}, {0, 12, 12, blue}},
+		},
+	}
+
+	for _, test := range tests {
+		test.gif.Config = image.Config{ColorModel: testPalette, Width: 16, Height: 16}
+
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, test.gif); err != nil {
+			t.Fatalf("Failed to encode the %s gif: %s", test.name, err)
+		}
+
+		img, err := DecodeGif(&buf, FitArea)
+		if err != nil {
+			t.Errorf("%s: failed to decode: %s", test.name, err)
+			continue
+		}
+
+		for _, p := range test.pixels {
+			if actual := img.Frames()[p.frame].RGBAAt(p.x, p.y); actual != p.expected {
+				t.Errorf("%s: frame %d (%d, %d) should be %v, not %v", test.name, p.frame, p.x, p.y, p.expected, actual)
+			}
+		}
+	}
+}
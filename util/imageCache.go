@@ -0,0 +1,248 @@
+package util
+
+import (
+	"image"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ninjasphere/go-ninja/config"
+)
+
+var enableImageWatch = config.Bool(true, "led.images.watch")
+
+// The decoded images, keyed by their cleaned path and fit mode. While the images directory is
+// being watched, an entry is decoded again whenever its file changes and every
+// CachedImage loaded from it picks up the new frames.
+var images = &imageCache{
+	entries: make(map[imageCacheKey]*imageCacheEntry),
+}
+
+type imageCacheKey struct {
+	path string
+	mode FitMode
+}
+
+type imageCacheEntry struct {
+	version int   // incremented each time the file is reloaded
+	image   Image // the decoded image, copied for each user
+}
+
+type imageCache struct {
+	sync.Mutex
+	entries  map[imageCacheKey]*imageCacheEntry
+	watching bool
+	watch    sync.Once
+}
+
+// CachedImage is an Image loaded through the image cache. It swaps in the new frames
+// the next time it is drawn after its file changes. If it's animated, the animation
+// can be controlled as an Animation, and the new frames are played on the same clock
+// (and stay paused, if it was paused).
+type CachedImage struct {
+	key     imageCacheKey
+	version int
+	image   Image
+	drawn   bool  // whether the current version has been drawn yet
+	clock   Clock // the clock set on the animation, or nil if it's the default
+	paused  bool
+}
+
+func (i *CachedImage) current() Image {
+	if img, version := images.since(i.key, i.version); img != nil {
+		if a, ok := img.(Animation); ok {
+			if i.clock != nil {
+				a.SetClock(i.clock)
+			}
+			if i.paused {
+				a.Pause()
+			}
+		}
+		i.image = img
+		i.version = version
+		i.drawn = false
+	}
+	return i.image
+}
+
+// animation answers the current image if it's animated, or nil if it isn't.
+func (i *CachedImage) animation() Animation {
+	a, _ := i.current().(Animation)
+	return a
+}
+
+func (i *CachedImage) GetNextFrame() *image.RGBA {
+	img := i.current()
+	i.drawn = true
+	return img.GetNextFrame()
+}
+
+// IsDirty answers whether the file has changed, or the animation has moved on, since the
+// image was last drawn. Cached still images never change otherwise.
+func (i *CachedImage) IsDirty() bool {
+	img := i.current()
+	if !i.drawn {
+		return true
+	}
+	if _, ok := img.(*SingleImage); ok {
+		return false
+	}
+	return IsImageDirty(img)
+}
+
+func (i *CachedImage) GetPositionFrame(position float64, blend bool) *image.RGBA {
+	return i.current().GetPositionFrame(position, blend)
+}
+
+func (i *CachedImage) FrameRate() float64 {
+	return ImageFrameRate(i.current())
+}
+
+func (i *CachedImage) Pause() {
+	i.paused = true
+	if a := i.animation(); a != nil {
+		a.Pause()
+	}
+}
+
+func (i *CachedImage) Resume() {
+	i.paused = false
+	if a := i.animation(); a != nil {
+		a.Resume()
+	}
+}
+
+func (i *CachedImage) Seek(elapsed time.Duration) {
+	if a := i.animation(); a != nil {
+		a.Seek(elapsed)
+	}
+}
+
+func (i *CachedImage) Reset() {
+	if a := i.animation(); a != nil {
+		a.Reset()
+	}
+}
+
+// SetClock sets the clock that drives the animation, and the animations of the new
+// frames after its file changes. The animation is reset.
+func (i *CachedImage) SetClock(clock Clock) {
+	i.clock = clock
+	if a := i.animation(); a != nil {
+		a.SetClock(clock)
+	}
+}
+
+// load answers the cached image for src, decoding it if it isn't cached or if nothing
+// is watching for changes to it.
+func (c *imageCache) load(src string, mode FitMode) (Image, error) {
+	c.watch.Do(c.startWatching)
+
+	key := imageCacheKey{filepath.Clean(src), mode}
+
+	c.Lock()
+	entry, ok := c.entries[key]
+	watching := c.watching
+	c.Unlock()
+
+	if !ok || !watching {
+		img, err := decodeImage(key.path, mode)
+		if err != nil {
+			return nil, err
+		}
+
+		c.Lock()
+		if entry, ok = c.entries[key]; ok {
+			entry.version++
+			entry.image = img
+		} else {
+			entry = &imageCacheEntry{image: img}
+			c.entries[key] = entry
+		}
+		c.Unlock()
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	return &CachedImage{
+		key:     key,
+		version: entry.version,
+		image:   instanceOf(entry.image),
+	}, nil
+}
+
+// since answers a copy of the cached image if it has changed since version.
+func (c *imageCache) since(key imageCacheKey, version int) (Image, int) {
+	c.Lock()
+	defer c.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || entry.version == version {
+		return nil, version
+	}
+	return instanceOf(entry.image), entry.version
+}
+
+// changed decodes an image again, in each of the modes it is used in, after its file
+// has changed. If it can't be decoded, the old frames are kept.
+func (c *imageCache) changed(src string) {
+	path := filepath.Clean(src)
+
+	if isSpriteTiming(path) {
+		path = path[:len(path)-len(".json")] + ".png"
+	}
+
+	c.Lock()
+	var keys []imageCacheKey
+	for key := range c.entries {
+		if key.path == path {
+			keys = append(keys, key)
+		}
+	}
+	c.Unlock()
+
+	reloaded := 0
+	for _, key := range keys {
+		// The other fit modes may still decode, so they're reloaded anyway
+		img, err := decodeImage(key.path, key.mode)
+		if err != nil {
+			log.Warningf("Failed to reload image %s (fit %s) : %s", path, key.mode, err)
+			continue
+		}
+
+		c.Lock()
+		entry := c.entries[key]
+		entry.version++
+		entry.image = img
+		c.Unlock()
+		reloaded++
+	}
+
+	if reloaded > 0 {
+		log.Infof("Reloaded image %s", path)
+	}
+}
+
+func (c *imageCache) startWatching() {
+	if !enableImageWatch {
+		return
+	}
+
+	if err := watchImageDir(imageDir, c.changed); err != nil {
+		log.Warningf("Not watching %s for changes: %s", imageDir, err)
+		return
+	}
+
+	c.Lock()
+	c.watching = true
+	c.Unlock()
+}
+
+// instanceOf answers an image sharing the frames of img, but with its own animation state.
+func instanceOf(img Image) Image {
+	if a, ok := img.(*AnimatedImage); ok {
+		return NewAnimatedImage(a.frames, a.delays, a.remainingLoops)
+	}
+	return img
+}
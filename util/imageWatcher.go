@@ -0,0 +1,91 @@
+// +build linux
+
+package util
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+const watchEvents = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE
+
+// watchImageDir uses inotify to call changed with the path of each file written or moved
+// into dir or any directory beneath it (e.g. images/user), including ones created later.
+func watchImageDir(dir string, changed func(path string)) error {
+	fd, err := syscall.InotifyInit()
+	if err != nil {
+		return err
+	}
+
+	dirs := make(map[int]string)
+
+	add := func(path string) {
+		wd, err := syscall.InotifyAddWatch(fd, path, watchEvents)
+		if err != nil {
+			log.Warningf("Failed to watch %s : %s", path, err)
+			return
+		}
+		dirs[wd] = path
+	}
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			add(path)
+		}
+		return nil
+	})
+	if err != nil {
+		syscall.Close(fd)
+		return err
+	}
+
+	log.Infof("Watching %d image directories under %s", len(dirs), dir)
+
+	go func() {
+		defer syscall.Close(fd)
+
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+
+		for {
+			n, err := syscall.Read(fd, buf)
+			if err != nil {
+				if err == syscall.EINTR {
+					continue
+				}
+				log.Errorf("Stopped watching images: %s", err)
+				return
+			}
+
+			for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				nameStart := offset + syscall.SizeofInotifyEvent
+				offset = nameStart + int(event.Len)
+
+				parent, ok := dirs[int(event.Wd)]
+				if !ok || event.Len == 0 {
+					continue
+				}
+
+				name := string(bytes.TrimRight(buf[nameStart:offset], "\x00"))
+				path := filepath.Join(parent, name)
+
+				switch {
+				case event.Mask&syscall.IN_ISDIR != 0:
+					if event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+						add(path)
+					}
+				case event.Mask&(syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO) != 0:
+					changed(path)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package util
+
+import (
+	"image"
+	"image/color"
+)
+
+var missingImageColor = color.RGBA{255, 0, 0, 255}
+
+var missingImageFrame = func() *image.RGBA {
+	frame := image.NewRGBA(image.Rect(0, 0, 16, 16))
+
+	// A red cross, inset by two pixels so it doesn't look like part of a neighbouring pane.
+	for i := 2; i < 14; i++ {
+		frame.Set(i, i, missingImageColor)
+		frame.Set(15-i, i, missingImageColor)
+	}
+
+	return frame
+}()
+
+// MissingImage answers the placeholder shown in place of an image that couldn't be loaded.
+func MissingImage() Image {
+	return NewSingleImage(missingImageFrame)
+}
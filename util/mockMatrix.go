@@ -3,6 +3,7 @@ package util
 import (
 	"fmt"
 	"io"
+	"sync"
 )
 
 const (
@@ -14,35 +15,60 @@ const (
 	maxWrite = 768 // the max number of bytes received in the stateData state
 )
 
+// The values the mock matrix answers to the firmware queries. The temperature is a cool
+// board (about 39°C), well below where the thermal governor starts dimming.
+const (
+	mockVersion     = "mock"
+	mockTemperature = 0x210
+)
+
 type mockMatrix struct {
-	state int // the current state of the connection
-	count int // number of bytes received since entering the data state
+	sync.Mutex
+	state   int        // the current state of the connection
+	count   int        // number of bytes received since entering the data state
+	replies []byte     // replies to firmware queries that have not been read yet
+	ready   *sync.Cond // signalled when the state or replies change
+
+	temperature int // the ADC temperature reading answered
 }
 
 // Answers a mock for the matrix that simulates a real led matrix.
 func newMockMatrix() io.ReadWriteCloser {
-	return &mockMatrix{
-		state: stateCmd,
-		count: 0,
+	m := &mockMatrix{
+		state:       stateCmd,
+		count:       0,
+		temperature: mockTemperature,
 	}
+	m.ready = sync.NewCond(&m.Mutex)
+	return m
 }
 
-// Answers 'F' when the mock matrix is in the swap state, otherwise answers empty.
+// Answers 'F' when the mock matrix is in the swap state, otherwise answers any pending
+// replies to firmware queries, blocking until there is something to answer.
 func (m *mockMatrix) Read(p []byte) (n int, err error) {
-	switch m.state {
-	case stateSwap:
+	m.Lock()
+	defer m.Unlock()
+
+	for {
 		if len(p) == 0 {
 			return 0, fmt.Errorf("insufficient capacity")
-		} else {
+		}
+
+		switch {
+		case m.state == stateClose:
+			return 0, fmt.Errorf("stream is closed")
+		case len(m.replies) > 0:
+			n = copy(p, m.replies)
+			m.replies = m.replies[n:]
+			return n, nil
+		case m.state == stateSwap:
 			p[0] = 'F'
 			m.count = 0
 			m.state = stateCmd
 			return 1, nil
 		}
-	case stateClose:
-		return 0, fmt.Errorf("stream is closed")
-	default:
-		return 0, nil
+
+		m.ready.Wait()
 	}
 }
 
@@ -52,13 +78,24 @@ func (m *mockMatrix) Read(p []byte) (n int, err error) {
 //
 // []byte{cmdWriteBuffer}, [768]byte, []byte{cmdSwapBuffers}...
 //
+// interleaved with the single byte firmware queries (cmdReadTemp, cmdReadRawTemp and
+// cmdReadVersion) whenever a command is expected.
+//
 // All other sequences will result in the connection moving into a closed state.
 func (m *mockMatrix) Write(p []byte) (n int, err error) {
+	m.Lock()
+	defer m.Unlock()
+	defer m.ready.Broadcast()
+
 	if len(p) == 0 {
 		return 0, nil
 	} else {
 	buffer:
 		for i, c := range p {
+			if (m.state == stateCmd || m.state == stateSwap) && m.query(c) {
+				continue
+			}
+
 			switch m.state {
 			case stateCmd:
 				switch c {
@@ -68,7 +105,7 @@ func (m *mockMatrix) Write(p []byte) (n int, err error) {
 				case stateSwap:
 					m.state = stateSwap
 				default:
-					old := *m
+					old := struct{ state, count int }{m.state, m.count}
 					m.state = stateClose
 					return i + 1, fmt.Errorf("unexpected command received while waiting for command: 0x%02x, %v", c, old)
 				}
@@ -91,7 +128,7 @@ func (m *mockMatrix) Write(p []byte) (n int, err error) {
 					m.state = stateData
 					m.count = 0
 				default:
-					old := *m
+					old := struct{ state, count int }{m.state, m.count}
 					m.state = stateClose
 					return i + 1, fmt.Errorf("unexpected byte received (0x%02x) while in swap state %v", c, old)
 				}
@@ -104,8 +141,33 @@ func (m *mockMatrix) Write(p []byte) (n int, err error) {
 
 }
 
+// SetTemperature sets the ADC value reported when the temperature is read, e.g. to
+// simulate a hot board.
+func (m *mockMatrix) SetTemperature(adc int) {
+	m.Lock()
+	defer m.Unlock()
+	m.temperature = adc
+}
+
+// Queues the reply to a firmware query, answering false if c is not a query.
+func (m *mockMatrix) query(c byte) bool {
+	switch c {
+	case cmdReadTemp, cmdReadRawTemp:
+		m.replies = append(m.replies, fmt.Sprintf("0x%03x\r\n", m.temperature)...)
+	case cmdReadVersion:
+		m.replies = append(m.replies, "V"+mockVersion+"\r\n"...)
+	default:
+		return false
+	}
+	return true
+}
+
 // Moves the connection into the closed state.
 func (m *mockMatrix) Close() error {
+	m.Lock()
+	defer m.Unlock()
+
 	m.state = stateClose
+	m.ready.Broadcast()
 	return nil
 }
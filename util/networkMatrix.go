@@ -0,0 +1,88 @@
+package util
+
+import (
+	"fmt"
+	"image"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ninjasphere/go-ninja/config"
+)
+
+var networkProtocol = config.String("udp", "led.network.protocol")
+var networkAddress = config.String("localhost:3116", "led.network.address")
+
+// networkMatrix sends each frame as 768 bytes of raw RGB to a remote host, over
+// tcp (a stream of frames) or udp (a datagram per frame). If the connection is
+// lost it is redialled on the next frame.
+type networkMatrix struct {
+	sync.Mutex
+	protocol string
+	address  string
+	conn     net.Conn
+}
+
+func newNetworkMatrix(protocol string, address string) *networkMatrix {
+	return &networkMatrix{
+		protocol: protocol,
+		address:  address,
+	}
+}
+
+func (m *networkMatrix) Open() error {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.dial()
+}
+
+func (m *networkMatrix) dial() error {
+	conn, err := net.DialTimeout(m.protocol, m.address, time.Second*5)
+	if err != nil {
+		return fmt.Errorf("Failed to connect to network matrix %s://%s : %s", m.protocol, m.address, err)
+	}
+	m.conn = conn
+	return nil
+}
+
+func (m *networkMatrix) WriteFrame(frame *image.RGBA) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.conn == nil {
+		if err := m.dial(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := m.conn.Write(RawFrame(frame)); err != nil {
+		m.conn.Close()
+		m.conn = nil
+		return fmt.Errorf("Failed writing frame to network matrix: %s", err)
+	}
+
+	return nil
+}
+
+func (m *networkMatrix) Close() error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.conn == nil {
+		return nil
+	}
+
+	err := m.conn.Close()
+	m.conn = nil
+	return err
+}
+
+func (m *networkMatrix) Info() MatrixInfo {
+	return MatrixInfo{
+		Driver: "network",
+		Device: m.protocol + "://" + m.address,
+		Width:  16,
+		Height: 16,
+	}
+}
@@ -0,0 +1,9 @@
+// +build !linux
+
+package util
+
+import "fmt"
+
+func watchImageDir(dir string, changed func(path string)) error {
+	return fmt.Errorf("watching for changes isn't supported on this platform")
+}
@@ -3,14 +3,16 @@
 package util
 
 import (
-	"image"
 	"io"
 )
 
+// There is no led matrix on this platform, so we emulate one.
 func GetLEDConnection() (io.ReadWriteCloser, error) {
-	panic("not implemented")
+	log.Infof("No LED matrix on this platform. Emulating one, writing %s frames to %s", emulatorSink, emulatorPath)
+
+	return newEmulatorConnection()
 }
 
-func WriteLEDMatrix(image *image.RGBA, s io.ReadWriteCloser) {
-	panic("not implemented")
+func ledDevice() string {
+	return "emulator:" + emulatorPath
 }
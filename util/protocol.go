@@ -0,0 +1,176 @@
+package util
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"math"
+)
+
+var cmdWriteBuffer byte = 1
+var cmdSwapBuffers byte = 2
+var cmdReadRawTemp byte = 'R'
+var cmdReadTemp byte = 'T'
+var cmdReadVersion byte = 'V'
+
+// The number of bytes in a converted frame (16x16 pixels, 3 bytes each)
+const frameSize = 768
+
+// From https://diarmuid.ie/blog/post/pwm-exponential-led-fading-on-arduino-or-other-platforms
+var R = (255 * math.Log10(2)) / (math.Log10(255))
+var ledAdjust = make(map[uint8]uint8)
+
+// The inverse of ledAdjust. Several inputs can map to the same output, so we
+// pick the smallest one (which keeps black as black).
+var ledUnadjust [256]uint8
+
+func init() {
+	for i := 0; i < 256; i++ {
+		ledAdjust[uint8(i)] = uint8(math.Pow(2, (float64(i)/R)) - 1)
+	}
+
+	for v := 0; v < 256; v++ {
+		ledUnadjust[v] = 255
+		for i := 0; i < 256; i++ {
+			if int(ledAdjust[uint8(i)]) >= v {
+				ledUnadjust[v] = uint8(i)
+				break
+			}
+		}
+	}
+}
+
+func ConvertImage(image *image.RGBA) []byte {
+
+	var frame [frameSize]byte
+
+	for inPos, outPos := 0, 0; inPos < len(image.Pix); inPos = inPos + 4 {
+
+		outPos = inPos / 4 * 3
+
+		frame[outPos] = ledAdjust[image.Pix[inPos]]
+		frame[outPos+1] = ledAdjust[image.Pix[inPos+1]]
+		frame[outPos+2] = ledAdjust[image.Pix[inPos+2]]
+	}
+
+	rows := split(frame[:], 16*3)
+
+	var orderedRows [][]byte
+	for i := 0; i < 8; i++ {
+		orderedRows = append(orderedRows, rows[i+8])
+		orderedRows = append(orderedRows, rows[i])
+	}
+
+	var finalFrame []byte
+
+	for _, line := range orderedRows {
+		for i, j := 0, len(line)-1; i < j; i, j = i+1, j-1 {
+			line[i], line[j] = line[j], line[i]
+		}
+
+		finalFrame = append(finalFrame, line...)
+	}
+
+	return finalFrame
+}
+
+// UnconvertImage reverses ConvertImage, undoing the row interleave, the mirroring
+// of each line and the led brightness adjustment. The colours are only approximate,
+// as the brightness adjustment loses information at the low end.
+func UnconvertImage(data []byte) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+
+	lineSize := 16 * 3
+
+	for line := 0; line < 16 && (line+1)*lineSize <= len(data); line++ {
+
+		// Even lines hold the bottom half of the image, odd lines the top half
+		row := line / 2
+		if line%2 == 0 {
+			row += 8
+		}
+
+		for i := 0; i < lineSize; i++ {
+			// Each line was sent mirrored (which also turned RGB into BGR)
+			pos := row*lineSize + (lineSize - 1 - i)
+
+			img.Pix[pos/3*4+pos%3] = ledUnadjust[data[line*lineSize+i]]
+		}
+	}
+
+	for pos := 3; pos < len(img.Pix); pos = pos + 4 {
+		img.Pix[pos] = 255
+	}
+
+	return img
+}
+
+// RawFrame answers the pixels of a frame as 768 bytes of RGB, row by row, with
+// no conversion for the matrix.
+func RawFrame(frame *image.RGBA) []byte {
+	raw := make([]byte, 0, frameSize)
+	for pos := 0; pos+3 < len(frame.Pix) && len(raw) < frameSize; pos = pos + 4 {
+		raw = append(raw, frame.Pix[pos], frame.Pix[pos+1], frame.Pix[pos+2])
+	}
+	return raw
+}
+
+// sendFrame writes an image into the led matrix's back buffer, and swaps it onto the display.
+func sendFrame(s io.Writer, image *image.RGBA) error {
+
+	//spew.Dump("writing image", image)
+
+	finalFrame := ConvertImage(image)
+
+	_, err := s.Write([]byte{cmdWriteBuffer})
+	if err != nil {
+		return fmt.Errorf("Failed writing write buffer command: %s", err)
+	}
+
+	_, err = s.Write(finalFrame[:])
+	if err != nil {
+		return fmt.Errorf("Failed writing frame: %s", err)
+	}
+
+	_, err = s.Write([]byte{cmdSwapBuffers})
+	if err != nil {
+		return fmt.Errorf("Failed writing swap buffer command: %s", err)
+	}
+
+	return nil
+}
+
+func split(a []byte, size int) [][]byte {
+	var out [][]byte
+	var i = 0
+	for i < len(a) {
+		out = append(out, a[i:i+size])
+		i += size
+	}
+
+	return out
+}
+
+// Simple RLE on zero values....
+/*
+func compress(frame []byte) []byte {
+	compressed := make([]byte, 0)
+	for i := 0; i < len(frame); i++ {
+
+		val := frame[i]
+		if val == 0 {
+
+			count := 0
+			for j := i + 1; j < len(frame) && frame[j] == val; j++ {
+				count++
+			}
+
+			compressed = append(compressed, val, byte(count))
+			i += count
+		} else {
+			compressed = append(compressed, val)
+		}
+	}
+	//spew.Dump("from", frame, compressed)
+	return compressed
+}*/
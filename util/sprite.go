@@ -0,0 +1,136 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Sprite sheets are pngs named like name.sprite16.png, holding a row of frames, each
+// 16 (or whatever the number is) pixels wide and as tall as the png. The frames can be
+// timed by a sidecar file with the same name, ending in .json instead of .png:
+//
+//   {"delay": 100, "delays": [500, 100, 100], "loopCount": 0}
+//
+// Delays are in milliseconds. "delay" is used for every frame missing from "delays",
+// and defaults to defaultSpriteDelay. "loopCount" means the same as in a gif, so the
+// animation loops forever if it is 0 (the default), plays once if it is -1, and
+// otherwise repeats that many times.
+
+var spritePattern = regexp.MustCompile(`(?i)\.sprite(\d+)\.png$`)
+
+const defaultSpriteDelay = 100
+
+type spriteTiming struct {
+	Delay     int   `json:"delay"`
+	Delays    []int `json:"delays"`
+	LoopCount int   `json:"loopCount"`
+}
+
+// spriteWidth answers the width of the frames in a sprite sheet, or 0 if src isn't one.
+func spriteWidth(src string) int {
+	match := spritePattern.FindStringSubmatch(src)
+	if match == nil {
+		return 0
+	}
+	width, _ := strconv.Atoi(match[1])
+	return width
+}
+
+// spriteTimingPath answers the path of the sidecar file timing a sprite sheet.
+func spriteTimingPath(src string) string {
+	return src[:len(src)-len(".png")] + ".json"
+}
+
+func decodeSprite(src string, mode FitMode) (*AnimatedImage, error) {
+	width := spriteWidth(src)
+	if width <= 0 {
+		return nil, fmt.Errorf("Bad sprite frame width in '%s'", src)
+	}
+
+	file, err := os.Open(src)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open sprite '%s' : %s", src, err)
+	}
+	defer file.Close()
+
+	sheet, err := png.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("PNG decoding failed on sprite '%s' : %s", src, err)
+	}
+
+	bounds := sheet.Bounds()
+	if bounds.Dx() == 0 || bounds.Dx()%width != 0 {
+		return nil, fmt.Errorf("Sprite '%s' is %d pixels wide, which isn't a multiple of %d", src, bounds.Dx(), width)
+	}
+
+	timing, err := readSpriteTiming(spriteTimingPath(src))
+	if err != nil {
+		return nil, err
+	}
+
+	rgba := toRGBA(sheet)
+	count := bounds.Dx() / width
+
+	var frames []*image.RGBA
+	var delays []int
+
+	for i := 0; i < count; i++ {
+		frame := rgba.SubImage(image.Rect(i*width, 0, (i+1)*width, rgba.Rect.Dy()))
+		frames = append(frames, FitImage(frame, mode))
+
+		delay := timing.Delay
+		if i < len(timing.Delays) {
+			delay = timing.Delays[i]
+		}
+		// Milliseconds to 100ths of a second, as used by gifs
+		delays = append(delays, (delay+5)/10)
+	}
+
+	// The same as a gif's loop count
+	loops := timing.LoopCount
+	if loops == 0 {
+		loops = -1
+	} else if loops == -1 {
+		loops = 0
+	}
+
+	return NewAnimatedImage(frames, delays, loops), nil
+}
+
+// readSpriteTiming reads the sidecar file for a sprite sheet, answering the default timing
+// if there isn't one.
+func readSpriteTiming(path string) (*spriteTiming, error) {
+	timing := &spriteTiming{
+		Delay: defaultSpriteDelay,
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return timing, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not read sprite timing '%s' : %s", path, err)
+	}
+
+	if err := json.Unmarshal(data, timing); err != nil {
+		return nil, fmt.Errorf("Bad sprite timing '%s' : %s", path, err)
+	}
+
+	if timing.Delay <= 0 {
+		timing.Delay = defaultSpriteDelay
+	}
+
+	return timing, nil
+}
+
+// isSpriteTiming answers whether path is the sidecar timing file of a sprite sheet.
+func isSpriteTiming(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), ".json") && spriteWidth(path[:len(path)-len(".json")]+".png") > 0
+}
@@ -0,0 +1,61 @@
+package util
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+var ErrSunAlwaysUp = fmt.Errorf("The sun doesn't set on this day")
+var ErrSunAlwaysDown = fmt.Errorf("The sun doesn't rise on this day")
+
+// SunriseSunset answers the times of sunrise and sunset on the day containing t, at
+// the given location (in degrees, north and east positive). It uses the simple sunrise
+// equation, which is accurate to a minute or two - plenty for dimming a display.
+// See https://en.wikipedia.org/wiki/Sunrise_equation
+func SunriseSunset(latitude float64, longitude float64, t time.Time) (sunrise time.Time, sunset time.Time, err error) {
+	rad := math.Pi / 180
+
+	// The start of the day we want, as a julian day
+	year, month, day := t.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	julian := float64(midnight.Unix())/86400 + 2440587.5
+
+	n := math.Ceil(julian - 2451545.0 + 0.0008)
+
+	// Mean solar noon
+	meanNoon := n - longitude/360
+
+	// Solar mean anomaly
+	m := math.Mod(357.5291+0.98560028*meanNoon, 360)
+
+	// Equation of the center
+	c := 1.9148*math.Sin(m*rad) + 0.02*math.Sin(2*m*rad) + 0.0003*math.Sin(3*m*rad)
+
+	// Ecliptic longitude
+	lambda := math.Mod(m+c+180+102.9372, 360)
+
+	transit := 2451545.0 + meanNoon + 0.0053*math.Sin(m*rad) - 0.0069*math.Sin(2*lambda*rad)
+
+	// Declination of the sun
+	declination := math.Asin(math.Sin(lambda*rad) * math.Sin(23.44*rad))
+
+	// Hour angle
+	cosHourAngle := (math.Sin(-0.833*rad) - math.Sin(latitude*rad)*math.Sin(declination)) / (math.Cos(latitude*rad) * math.Cos(declination))
+
+	if cosHourAngle < -1 {
+		return time.Time{}, time.Time{}, ErrSunAlwaysUp
+	}
+	if cosHourAngle > 1 {
+		return time.Time{}, time.Time{}, ErrSunAlwaysDown
+	}
+
+	hourAngle := math.Acos(cosHourAngle) / rad
+
+	fromJulian := func(j float64) time.Time {
+		seconds := (j - 2440587.5) * 86400
+		return time.Unix(int64(seconds), 0).In(t.Location())
+	}
+
+	return fromJulian(transit - hourAngle/360), fromJulian(transit + hourAngle/360), nil
+}